@@ -0,0 +1,156 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"azuremyst.org/go-home-sensors/sensors"
+)
+
+const usageText = `Usage:
+  %[1]s [flags]
+	Collect and export the readings of the configured sensors.
+  %[1]s [flags] sensor <name> <command> [--<argument> <value> ...]
+	Run a maintenance command on the sensor configured in [sensors.<name>], for example
+	%[1]s sensor scd4x frc --ppm 420
+	Refuses to run while the daemon holds the I²C bus.
+
+Commands of every sensor:
+  read [--once]
+	Print the readings at the configured frequency, or only the first one.
+
+Flags:
+`
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), usageText, filepath.Base(os.Args[0]))
+	flag.PrintDefaults()
+}
+
+func runSensorCommand(conf Config, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: sensor <name> <command> [--<argument> <value> ...]")
+	}
+	senName, command := args[0], args[1]
+	senConfig, ok := conf.Sensors[senName]
+	if !ok {
+		return fmt.Errorf("sensor %s is not configured", senName)
+	}
+	sensorInstance := sensors.Sniff(senName)
+	if sensorInstance == nil {
+		return fmt.Errorf("sensor %s is not supported", senName)
+	}
+
+	operations := make(map[string]sensors.Operation)
+	if maintainable, ok := (*sensorInstance).(sensors.Maintainable); ok {
+		operations = maintainable.Operations()
+	}
+	operation, ok := operations[command]
+	if !ok && command != "read" {
+		return fmt.Errorf("unknown command %q for sensor %s, available:\n%s", command, senName, describeOperations(operations))
+	}
+
+	var operationArgs map[string]string
+	if ok {
+		parsed, err := parseOperationArgs(command, operation, args[2:])
+		if err != nil {
+			return err
+		}
+		operationArgs = parsed
+	}
+
+	b, lock, err := openBus(conf)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	defer b.Close()
+
+	sensor := initializeSensor(b, senName, senConfig, conf.Labels)
+	if sensor == nil {
+		return fmt.Errorf("sensor %s is not supported", senName)
+	}
+
+	if command == "read" {
+		return readSensor(sensor, conf.Frequency, args[2:])
+	}
+	// The operations checked above belong to an instance that was never initialized.
+	operation = sensor.Sensor.(sensors.Maintainable).Operations()[command]
+
+	result, err := operation.Run(operationArgs)
+	if err != nil {
+		return fmt.Errorf("%s failed: %v", command, err)
+	}
+	fmt.Println(result)
+	return nil
+}
+
+func parseOperationArgs(command string, operation sensors.Operation, args []string) (map[string]string, error) {
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	values := make(map[string]*string, len(operation.Args))
+	for _, arg := range operation.Args {
+		values[arg] = flags.String(arg, "", "")
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	parsed := make(map[string]string, len(values))
+	for arg, value := range values {
+		if *value == "" {
+			return nil, fmt.Errorf("%s requires --%s", command, arg)
+		}
+		parsed[arg] = *value
+	}
+	return parsed, nil
+}
+
+func describeOperations(operations map[string]sensors.Operation) string {
+	names := make([]string, 0, len(operations)+1)
+	for name := range operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var description strings.Builder
+	description.WriteString("  read [--once]\n\tPrint the readings\n")
+	for _, name := range names {
+		operation := operations[name]
+		description.WriteString("  " + name)
+		for _, arg := range operation.Args {
+			description.WriteString(" --" + arg + " <value>")
+		}
+		description.WriteString("\n\t" + operation.Description + "\n")
+	}
+	return description.String()
+}
+
+// readSensor prints the readings of the sensor, waiting one interval before each reading so
+// the sensor completes a measurement after its initialization.
+func readSensor(sensor *configuredSensor, interval time.Duration, args []string) error {
+	flags := flag.NewFlagSet("read", flag.ContinueOnError)
+	once := flags.Bool("once", false, "Print a single reading")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	for {
+		time.Sleep(interval)
+		for _, recording := range sensor.collect() {
+			labels := []string{fmt.Sprintf("%s=%q", sensors.SensorName, recording.Sensor)}
+			for k, v := range recording.Metadata {
+				labels = append(labels, fmt.Sprintf("%s=%q", k, v))
+			}
+			sort.Strings(labels[1:])
+			fmt.Printf("%s{%s} %v %s\n", recording.Measure.ID, strings.Join(labels, ","), recording.Value, recording.Measure.Unit)
+		}
+		if *once {
+			return nil
+		}
+	}
+}
@@ -13,18 +13,22 @@ import (
 
 type PrometheusExporter struct {
 	gauges map[string]*prometheus.GaugeVec
+	labels []string
 }
 
-func CreateExporter() exporters.Exporter {
+// CreateExporter registers a gauge per measurement, labels are the names of the static labels
+// attached to the recordings in addition to the measurement labels.
+func CreateExporter(labels []string) exporters.Exporter {
 	gauges := make(map[string]*prometheus.GaugeVec, 0)
 	for _, measurement := range sensors.Measurements {
+		labelNames := append(append([]string{}, measurement.Labels...), labels...)
 		gauges[measurement.ID] = promauto.NewGaugeVec(prometheus.GaugeOpts{
 			Name: measurement.ID,
 			Help: measurement.Description,
-		}, measurement.Labels)
+		}, labelNames)
 	}
 	http.Handle("/metrics", promhttp.Handler())
-	return &PrometheusExporter{gauges: gauges}
+	return &PrometheusExporter{gauges: gauges, labels: labels}
 }
 
 func (pe *PrometheusExporter) Export(recordings []sensors.MeasurementRecording) {
@@ -36,11 +40,14 @@ func (pe *PrometheusExporter) Export(recordings []sensors.MeasurementRecording)
 			continue
 		}
 
-		extendedLabels := make(map[string]string, len(metricRecording.Metadata)+1)
+		extendedLabels := make(map[string]string, len(metricRecording.Metadata)+len(pe.labels)+1)
 		extendedLabels[string(sensors.SensorName)] = metricRecording.Sensor
 		for k, v := range metricRecording.Metadata {
 			extendedLabels[string(k)] = v
 		}
+		for _, label := range pe.labels {
+			extendedLabels[label] = metricRecording.Labels[label]
+		}
 		gauge.With(extendedLabels).Set(metricRecording.Value)
 	}
 }
@@ -123,6 +123,11 @@ func (pe *SqliteExporter) Export(recordings []sensors.MeasurementRecording) {
 				log.ErrorLog.Fatalf("Failed to insert metadata asociated with recording: %q", err)
 			}
 		}
+		for k, v := range recording.Labels {
+			if _, err = stmtMetadata.Exec(k, v, insertId); err != nil {
+				log.ErrorLog.Fatalf("Failed to insert labels asociated with recording: %q", err)
+			}
+		}
 	}
 
 	if err = tx.Commit(); err != nil {
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"periph.io/x/conn/v3/i2c/i2creg"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+var (
+	// busDeviceFile is the device file of a numbered bus, as exposed by the i2c-dev kernel module.
+	busDeviceFile = "/dev/i2c-%d"
+	// busLockDir holds the locks of the buses without a device file. It is not a temporary
+	// directory as those may be private to the service, e.g. systemd PrivateTmp.
+	busLockDir = "/run/home-sensors"
+)
+
+// lockBus takes an exclusive lock on the I²C bus so the daemon and the maintenance commands never
+// talk to the sensors at the same time. The lock is held until the returned file is closed or the
+// process exits.
+func lockBus(busName string) (*os.File, error) {
+	path := busLockPath(busName)
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+			file, err = os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0644)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bus lock %s: %v", path, err)
+	}
+
+	if err := lockFile(file, path); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// busLockPath is the device file of the bus, so the lock is shared by every process on the host
+// whatever name of the bus they were given. periph names the buses e.g. "I2C1", the device file
+// is found from the bus number. Buses without a device file use a file in busLockDir instead.
+func busLockPath(busName string) string {
+	if ref := findBus(busName); ref != nil {
+		if ref.Number >= 0 {
+			device := fmt.Sprintf(busDeviceFile, ref.Number)
+			if _, err := os.Stat(device); err == nil {
+				return device
+			}
+		}
+		busName = ref.Name
+	}
+	return filepath.Join(busLockDir, unsafeFileChars.ReplaceAllString(busName, "_")+".lock")
+}
+
+// findBus returns the bus i2creg.Open opens for the name, the one with the lowest number when
+// the name is empty.
+func findBus(busName string) *i2creg.Ref {
+	var found *i2creg.Ref
+	for _, ref := range i2creg.All() {
+		if busName == "" {
+			if found == nil || (ref.Number >= 0 && (found.Number < 0 || ref.Number < found.Number)) {
+				found = ref
+			}
+			continue
+		}
+		if ref.Name == busName || fmt.Sprint(ref.Number) == busName {
+			return ref
+		}
+		for _, alias := range ref.Aliases {
+			if alias == busName {
+				return ref
+			}
+		}
+	}
+	return found
+}
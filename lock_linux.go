@@ -0,0 +1,21 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive lock on the file, failing when another process holds it.
+func lockFile(file *os.File, path string) error {
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return fmt.Errorf("I²C bus %s is in use by another process, is the daemon running?", path)
+		}
+		return fmt.Errorf("failed to lock bus %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+//go:build linux
+
+package main
+
+import "testing"
+
+func TestLockBusExclusive(t *testing.T) {
+	busLockDir = t.TempDir()
+	t.Cleanup(func() { busLockDir = "/run/home-sensors" })
+
+	daemon, err := lockBus("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cli, err := lockBus("test"); err == nil {
+		cli.Close()
+		t.Fatal("second lock on the bus succeeded")
+	}
+	daemon.Close()
+	cli, err := lockBus("test")
+	if err != nil {
+		t.Fatalf("lock after release failed: %v", err)
+	}
+	cli.Close()
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "os"
+
+// lockFile is a no-op, the bus is only locked on linux.
+func lockFile(file *os.File, path string) error {
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"periph.io/x/conn/v3/i2c"
+	"periph.io/x/conn/v3/i2c/i2creg"
+)
+
+func registerTestBus(t *testing.T, name string, aliases []string, number int) {
+	t.Helper()
+	opener := func() (i2c.BusCloser, error) { return nil, os.ErrNotExist }
+	if err := i2creg.Register(name, aliases, number, opener); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { i2creg.Unregister(name) })
+}
+
+func TestBusLockPath(t *testing.T) {
+	dir := t.TempDir()
+	busDeviceFile, busLockDir = filepath.Join(dir, "i2c-%d"), filepath.Join(dir, "run")
+	t.Cleanup(func() { busDeviceFile, busLockDir = "/dev/i2c-%d", "/run/home-sensors" })
+	if err := os.WriteFile(filepath.Join(dir, "i2c-1"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	registerTestBus(t, "I2C1", []string{"/dev/i2c-1"}, 1)
+	registerTestBus(t, "FT232H", nil, -1)
+
+	tests := []struct {
+		bus  string
+		want string
+	}{
+		{"", filepath.Join(dir, "i2c-1")},
+		{"I2C1", filepath.Join(dir, "i2c-1")},
+		{"1", filepath.Join(dir, "i2c-1")},
+		{"/dev/i2c-1", filepath.Join(dir, "i2c-1")},
+		{"FT232H", filepath.Join(dir, "run", "FT232H.lock")},
+		{"missing/bus", filepath.Join(dir, "run", "missing_bus.lock")},
+	}
+	for _, tt := range tests {
+		// The daemon and the command line may have different temporary directories.
+		t.Setenv("TMPDIR", "/tmp/daemon")
+		daemon := busLockPath(tt.bus)
+		t.Setenv("TMPDIR", "/tmp/cli")
+		cli := busLockPath(tt.bus)
+
+		if daemon != tt.want || cli != tt.want {
+			t.Errorf("busLockPath(%q) = %q for the daemon and %q for the command line, want %q", tt.bus, daemon, cli, tt.want)
+		}
+	}
+}
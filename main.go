@@ -3,47 +3,108 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"syscall"
 	"time"
 
 	"azuremyst.org/go-home-sensors/exporters"
 	"azuremyst.org/go-home-sensors/exporters/prometheus"
 	"azuremyst.org/go-home-sensors/exporters/sqlite"
 	"azuremyst.org/go-home-sensors/log"
+	"azuremyst.org/go-home-sensors/maintenance"
+	"azuremyst.org/go-home-sensors/processors"
+	"azuremyst.org/go-home-sensors/processors/aqi"
+	"azuremyst.org/go-home-sensors/processors/correction"
+	"azuremyst.org/go-home-sensors/processors/fusion"
 	"azuremyst.org/go-home-sensors/sensors"
+	"azuremyst.org/go-home-sensors/serial"
 
 	_ "azuremyst.org/go-home-sensors/sensors/bosch"
 	_ "azuremyst.org/go-home-sensors/sensors/plantower"
+	_ "azuremyst.org/go-home-sensors/sensors/senseair"
 	_ "azuremyst.org/go-home-sensors/sensors/sensirion"
+	_ "azuremyst.org/go-home-sensors/sensors/st"
+	_ "azuremyst.org/go-home-sensors/sensors/winsen"
 
 	"github.com/BurntSushi/toml"
+	"periph.io/x/conn/v3/i2c"
 	"periph.io/x/conn/v3/i2c/i2creg"
 	"periph.io/x/host/v3"
 )
 
-func recordMetrics(interval time.Duration, sens []sensors.Sensor, exps []exporters.Exporter) {
+// configuredSensor is an initialized sensor together with the calibration and static labels of its readings.
+type configuredSensor struct {
+	sensors.Sensor
+	instance     string // Section name of a further device of the family, reported as sensor
+	calibrations map[string]sensors.Calibration
+	labels       map[string]string
+}
+
+func (cs *configuredSensor) collect() []sensors.MeasurementRecording {
+	recordings := sensors.Calibrate(cs.Collect(), cs.calibrations)
+	for i := range recordings {
+		if cs.instance != "" {
+			recordings[i].Sensor = cs.instance
+		}
+		recordings[i].Labels = cs.labels
+	}
+	return recordings
+}
+
+// recordMetrics runs the collection loop until stop is closed, the returned channel is closed once
+// the loop finished its cycle or maintenance operation and no longer uses the sensors.
+func recordMetrics(interval time.Duration, sens []*configuredSensor, procs []processors.Processor, exps []exporters.Exporter, maint *maintenance.Service, stop <-chan struct{}) <-chan struct{} {
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
 		log.InfoLog.Println("Collecting sensor data")
 
 		for {
 			collectedMeasurements := make([]sensors.MeasurementRecording, 0)
 			for _, sen := range sens {
-				collectedMeasurements = append(collectedMeasurements, sen.Collect()...)
+				collectedMeasurements = append(collectedMeasurements, sen.collect()...)
+			}
+
+			for _, sen := range sens {
+				if observer, ok := sen.Sensor.(sensors.Observer); ok {
+					observer.Observe(collectedMeasurements)
+				}
+			}
+
+			for _, proc := range procs {
+				collectedMeasurements = proc.Process(collectedMeasurements)
 			}
 
 			for _, exp := range exps {
 				exp.Export(collectedMeasurements)
 			}
-			time.Sleep(interval)
+
+			// Maintenance operations run while waiting for the next cycle, never during one.
+			next := time.After(interval)
+			for waiting := true; waiting; {
+				select {
+				case request := <-maint.Requests():
+					request.Run()
+				case <-next:
+					waiting = false
+				case <-stop:
+					return
+				}
+			}
 		}
 	}()
+	return done
 }
 
 func initializeExporters(conf Config) []exporters.Exporter {
 	initializeExporters := make([]exporters.Exporter, 0)
 	if conf.Exporters.Prometheus.Enable {
-		initializeExporters = append(initializeExporters, prometheus.CreateExporter())
+		initializeExporters = append(initializeExporters, prometheus.CreateExporter(staticLabelNames(conf)))
 	}
 
 	if conf.Exporters.Sqlite.Enable {
@@ -53,18 +114,118 @@ func initializeExporters(conf Config) []exporters.Exporter {
 	return initializeExporters
 }
 
+func initializeProcessors(conf Config) []processors.Processor {
+	initializedProcessors := make([]processors.Processor, 0)
+	if conf.Processors.PMCorrection.Enable {
+		initializedProcessors = append(initializedProcessors, correction.CreateProcessor(conf.Processors.PMCorrection))
+	}
+
+	if conf.Processors.Fusion.Enable {
+		initializedProcessors = append(initializedProcessors, fusion.CreateProcessor(conf.Processors.Fusion))
+	}
+
+	if conf.Processors.AQI.Enable {
+		initializedProcessors = append(initializedProcessors, aqi.CreateProcessor(conf.Processors.AQI))
+	}
+
+	return initializedProcessors
+}
+
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// staticLabelNames lists every static label configured globally or for any sensor.
+func staticLabelNames(conf Config) []string {
+	names := make(map[string]bool)
+	for name := range conf.Labels {
+		names[name] = true
+	}
+	for _, senConfig := range conf.Sensors {
+		for name := range senConfig.Labels {
+			names[name] = true
+		}
+	}
+
+	labelNames := make([]string, 0, len(names))
+	for name := range names {
+		labelNames = append(labelNames, name)
+	}
+	sort.Strings(labelNames)
+	return labelNames
+}
+
+func validateLabels(labels map[string]string) error {
+	for name := range labels {
+		if !labelNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+		for _, measurement := range sensors.Measurements {
+			for _, reserved := range measurement.Labels {
+				if name == reserved {
+					return fmt.Errorf("label %q is reserved by measurement %s", name, measurement.ID)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// sensorLabels merges the global labels with the ones of the sensor, the latter take precedence.
+func sensorLabels(global map[string]string, sensor map[string]string) map[string]string {
+	labels := make(map[string]string, len(global)+len(sensor))
+	for k, v := range global {
+		labels[k] = v
+	}
+	for k, v := range sensor {
+		labels[k] = v
+	}
+	return labels
+}
+
+// loadConfig decodes the config file, the sensor sections are kept around so the sensors can
+// decode their own options from them.
+func loadConfig(path string) (Config, error) {
+	var conf Config
+	md, err := toml.DecodeFile(path, &conf)
+	if err != nil {
+		return conf, err
+	}
+
+	conf.Sensors = make(map[string]SensorConfig, len(conf.RawSensors))
+	for senName, primitive := range conf.RawSensors {
+		primitive := primitive
+		var senConfig SensorConfig
+		if err := md.PrimitiveDecode(primitive, &senConfig); err != nil {
+			return conf, fmt.Errorf("sensor %s: %v", senName, err)
+		}
+		senConfig.options = func(v interface{}) error {
+			return md.PrimitiveDecode(primitive, v)
+		}
+		conf.Sensors[senName] = senConfig
+	}
+	return conf, nil
+}
+
 type (
 	Config struct {
-		Bus       string
-		Sensors   map[string]SensorConfig
-		Exporters MetricExporters
-		Port      int
-		Frequency time.Duration
+		Bus         string
+		Labels      map[string]string         // Static labels attached to the readings of every sensor
+		RawSensors  map[string]toml.Primitive `toml:"sensors"`
+		Sensors     map[string]SensorConfig   `toml:"-"`
+		Processors  MetricProcessors
+		Exporters   MetricExporters
+		Maintenance maintenance.Config
+		Port        int
+		Frequency   time.Duration
 	}
 
 	SensorConfig struct {
-		Enable   bool
-		Register uint16
+		Enable      bool
+		Register    uint16
+		Calibration map[string]sensors.Calibration // Keyed by Measurement.ID
+		Labels      map[string]string
+		Serial      *serial.Config // Port of the sensors using a serial port instead of the I²C bus
+
+		options func(v interface{}) error // Decodes the sensor specific options of the section
 	}
 
 	sqliteExporter struct {
@@ -76,33 +237,110 @@ type (
 		Enable bool
 	}
 
+	MetricProcessors struct {
+		PMCorrection correction.Config `toml:"pm_correction"`
+		Fusion       fusion.Config
+		AQI          aqi.Config
+	}
+
 	MetricExporters struct {
 		Prometheus prometheusExporter
 		Sqlite     sqliteExporter
 	}
 )
 
+// openBus initializes periph, locks the I²C bus for this process and opens it.
+func openBus(conf Config) (i2c.BusCloser, *os.File, error) {
+	// Make sure periph is initialized.
+	if _, err := host.Init(); err != nil {
+		return nil, nil, err
+	}
+
+	lock, err := lockBus(conf.Bus)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Use i2creg I²C bus registry to find the first available I²C bus.
+	b, err := i2creg.Open(conf.Bus)
+	if err != nil {
+		lock.Close()
+		return nil, nil, err
+	}
+	return b, lock, nil
+}
+
+// initializeSensor configures and initializes the sensor of the given config section on the I²C
+// bus or its serial port, it returns nil when the sensor is not supported or its port fails to open.
+func initializeSensor(b i2c.Bus, senName string, senConfig SensorConfig, globalLabels map[string]string) *configuredSensor {
+	if err := sensors.RegisterCalibrations(senConfig.Calibration); err != nil {
+		log.ErrorLog.Fatalf("Invalid calibration for sensor %s: %v", senName, err)
+	}
+	sensorInstance := sensors.Sniff(senName)
+	if nil == sensorInstance {
+		log.ErrorLog.Println("Sensor " + senName + " not supported!")
+		return nil
+	}
+
+	sensor := *sensorInstance
+	if configurable, ok := sensor.(sensors.Configurable); ok {
+		if err := configurable.Configure(senConfig.options); err != nil {
+			log.ErrorLog.Fatalf("Invalid configuration for sensor %s: %v", senName, err)
+		}
+	}
+	if serialSensor, ok := sensor.(sensors.SerialSensor); ok {
+		if senConfig.Serial == nil {
+			log.ErrorLog.Fatalf("Sensor %s requires a [sensors.%s.serial] section", senName, senName)
+		}
+		port, err := serial.Open(*senConfig.Serial)
+		if err != nil {
+			log.ErrorLog.Printf("Failed to open the serial port of sensor %s: %q", senName, err)
+			return nil
+		}
+		serialSensor.InitializeSerial(port)
+	} else {
+		sensor.Initialize(b, senConfig.Register)
+	}
+	var instance string
+	if sensors.SectionFamily(senName) != senName {
+		instance = senName
+	}
+	return &configuredSensor{
+		Sensor:       sensor,
+		instance:     instance,
+		calibrations: senConfig.Calibration,
+		labels:       sensorLabels(globalLabels, senConfig.Labels),
+	}
+}
+
 func main() {
 	configLocation := flag.String("config.file", "config.toml", "Configuration in toml format")
+	flag.Usage = usage
 	flag.Parse()
 
-	var conf Config
-	_, err := toml.DecodeFile(*configLocation, &conf)
+	conf, err := loadConfig(*configLocation)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Please provide a valid config file via `--config.file` parameter. Unable to read: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Make sure periph is initialized.
-	if _, err := host.Init(); err != nil {
-		log.ErrorLog.Fatal(err)
+	if flag.NArg() > 0 {
+		if flag.Arg(0) != "sensor" {
+			usage()
+			os.Exit(2)
+		}
+		if err := runSensorCommand(conf, flag.Args()[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		return
 	}
 
-	// Use i2creg I²C bus registry to find the first available I²C bus.
-	b, err := i2creg.Open(conf.Bus)
+	b, lock, err := openBus(conf)
 	if err != nil {
 		log.ErrorLog.Fatal(err)
 	}
+	defer lock.Close()
 	defer b.Close()
 
 	log.InfoLog.Println("Supported sensors:")
@@ -111,29 +349,77 @@ func main() {
 		log.InfoLog.Printf("\t%s\n", s)
 	}
 
+	if err := validateLabels(conf.Labels); err != nil {
+		log.ErrorLog.Fatalf("Invalid labels: %v", err)
+	}
+	for senName, senConfig := range conf.Sensors {
+		if err := validateLabels(senConfig.Labels); err != nil {
+			log.ErrorLog.Fatalf("Invalid labels for sensor %s: %v", senName, err)
+		}
+		// The exporters register the "_raw" measurements of the calibrations too.
+		if err := sensors.RegisterCalibrations(senConfig.Calibration); err != nil {
+			log.ErrorLog.Fatalf("Invalid calibration for sensor %s: %v", senName, err)
+		}
+	}
+
 	initializedExporters := initializeExporters(conf)
 	if len(initializedExporters) == 0 {
 		log.ErrorLog.Panicln("No exporter was configured!")
 	}
 
-	initializedSensors := make([]sensors.Sensor, 0)
+	initializedSensors := make([]*configuredSensor, 0)
+	maintainable := make(map[string]sensors.Maintainable)
 	for senName, senConfig := range conf.Sensors {
 		if !senConfig.Enable {
 			log.InfoLog.Printf("Sensor %s is disabled.\n", senName)
 			continue
 		}
-		sensorInstance := sensors.Sniff(senName)
-		if nil == sensorInstance {
-			log.ErrorLog.Println("Sensor " + senName + " not supported!")
-		} else {
-			sensor := *sensorInstance
-			sensor.Initialize(b, senConfig.Register)
+		if sensor := initializeSensor(b, senName, senConfig, conf.Labels); sensor != nil {
 			initializedSensors = append(initializedSensors, sensor)
+			if m, ok := sensor.Sensor.(sensors.Maintainable); ok {
+				maintainable[senName] = m
+			}
 		}
 	}
 
-	recordMetrics(conf.Frequency, initializedSensors, initializedExporters)
+	var maint *maintenance.Service
+	if conf.Maintenance.Enable {
+		maint = maintenance.CreateService(conf.Maintenance, maintainable)
+	}
+
+	stop := make(chan struct{})
+	stopped := recordMetrics(conf.Frequency, initializedSensors, initializeProcessors(conf), initializedExporters, maint, stop)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- http.ListenAndServe(fmt.Sprintf(":%d", conf.Port), nil)
+	}()
 
 	log.InfoLog.Printf("Started sensor collection service at %d \n", conf.Port)
-	http.ListenAndServe(fmt.Sprintf(":%d", conf.Port), nil)
+	select {
+	case sig := <-signals:
+		log.InfoLog.Printf("Received %s, shutting down", sig)
+	case err := <-serverErr:
+		log.ErrorLog.Printf("HTTP server stopped: %q", err)
+	}
+	close(stop)
+	<-stopped
+	closeSensors(initializedSensors)
+	if err := maint.Close(); err != nil {
+		log.ErrorLog.Printf("Failed to close maintenance audit log: %q", err)
+	}
+}
+
+// closeSensors lets the sensors keeping state, e.g. the SEN5x VOC algorithm, save it before exiting.
+// The collection loop has to be stopped, Close must not overlap a Collect or an operation.
+func closeSensors(sens []*configuredSensor) {
+	for _, sen := range sens {
+		if closer, ok := sen.Sensor.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				log.ErrorLog.Printf("Failed to close sensor %s: %q", sen.Name(), err)
+			}
+		}
+	}
 }
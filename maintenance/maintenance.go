@@ -0,0 +1,274 @@
+package maintenance
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"azuremyst.org/go-home-sensors/log"
+	"azuremyst.org/go-home-sensors/sensors"
+)
+
+// Requests waiting for the collection loop, further ones are rejected until it catches up.
+const queueSize = 8
+
+type Config struct {
+	Enable bool
+	// Bearer token the requests have to present in the Authorization header.
+	Token string
+	// File the outcome of every request is appended to as JSON lines, the info log without it.
+	AuditLog string `toml:"audit_log"`
+}
+
+// Service exposes the maintenance operations of the sensors over HTTP:
+//
+//	GET  /maintenance/                      lists the operations per sensor
+//	POST /maintenance/<sensor>/<operation>  runs an operation, arguments are passed as form values
+//
+// Operations are queued and run by the collection loop between two cycles, so they never
+// interleave with the readings of the sensor.
+type Service struct {
+	token   string
+	sensors map[string]sensors.Maintainable
+	queue   chan *Request
+
+	auditMu   sync.Mutex // Requests are audited from the HTTP handlers and the collection loop
+	auditFile *os.File
+	audit     *json.Encoder
+}
+
+// Request is a queued operation, Run executes it and answers the waiting HTTP request.
+type Request struct {
+	service   *Service
+	sensor    string
+	operation sensors.Operation
+	name      string
+	args      map[string]string
+	remote    string
+	response  chan response
+}
+
+type response struct {
+	Sensor    string `json:"sensor"`
+	Operation string `json:"operation"`
+	Result    string `json:"result,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type auditEntry struct {
+	Time      time.Time         `json:"time"`
+	Remote    string            `json:"remote"`
+	Sensor    string            `json:"sensor"`
+	Operation string            `json:"operation"`
+	Args      map[string]string `json:"args,omitempty"`
+	Status    int               `json:"status"`
+	Duration  string            `json:"duration,omitempty"`
+	Result    string            `json:"result,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// CreateService registers the maintenance endpoints for the given sensors, keyed by the name of
+// their config section.
+func CreateService(conf Config, maintainable map[string]sensors.Maintainable) *Service {
+	if conf.Token == "" {
+		log.ErrorLog.Fatalf("Maintenance API requires a token")
+	}
+
+	service := &Service{
+		token:   conf.Token,
+		sensors: maintainable,
+		queue:   make(chan *Request, queueSize),
+	}
+	if conf.AuditLog != "" {
+		file, err := os.OpenFile(conf.AuditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+		if err != nil {
+			log.ErrorLog.Fatalf("Failed to open maintenance audit log: %q", err)
+		}
+		service.auditFile = file
+		service.audit = json.NewEncoder(file)
+	}
+
+	http.Handle("/maintenance/", service)
+	return service
+}
+
+// Requests delivers the queued operations, nil when the service is disabled.
+func (s *Service) Requests() <-chan *Request {
+	if s == nil {
+		return nil
+	}
+	return s.queue
+}
+
+// Close flushes and closes the audit log, requests still answered afterwards are audited to the
+// info log.
+func (s *Service) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+	if s.auditFile == nil {
+		return nil
+	}
+	err := s.auditFile.Sync()
+	if closeErr := s.auditFile.Close(); err == nil {
+		err = closeErr
+	}
+	s.auditFile, s.audit = nil, nil
+	return err
+}
+
+func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/maintenance/"), "/")
+	sensorName, operationName, found := strings.Cut(path, "/")
+	// Every rejected request is audited too, e.g. to spot someone guessing the token.
+	reject := func(status int, message string) {
+		s.record(auditEntry{
+			Time:      time.Now(),
+			Remote:    r.RemoteAddr,
+			Sensor:    sensorName,
+			Operation: operationName,
+			Status:    status,
+			Error:     message,
+		})
+		http.Error(w, message, status)
+	}
+
+	token, bearer := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !bearer || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="maintenance"`)
+		reject(http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	if path == "" && r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, s.describe())
+		return
+	}
+
+	if !found {
+		reject(http.StatusNotFound, "404 page not found")
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		reject(http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	sensor, ok := s.sensors[sensorName]
+	if !ok {
+		reject(http.StatusNotFound, "unknown sensor "+sensorName)
+		return
+	}
+	operation, ok := sensor.Operations()[operationName]
+	if !ok {
+		reject(http.StatusNotFound, "unknown operation "+operationName)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		reject(http.StatusBadRequest, err.Error())
+		return
+	}
+	args := make(map[string]string, len(operation.Args))
+	for _, arg := range operation.Args {
+		value := r.Form.Get(arg)
+		if value == "" {
+			reject(http.StatusBadRequest, operationName+" requires "+arg)
+			return
+		}
+		args[arg] = value
+	}
+
+	request := &Request{
+		service:   s,
+		sensor:    sensorName,
+		operation: operation,
+		name:      operationName,
+		args:      args,
+		remote:    r.RemoteAddr,
+		response:  make(chan response, 1),
+	}
+	select {
+	case s.queue <- request:
+	default:
+		reject(http.StatusServiceUnavailable, "too many pending operations")
+		return
+	}
+
+	select {
+	case resp := <-request.response:
+		status := http.StatusOK
+		if resp.Error != "" {
+			status = http.StatusInternalServerError
+		}
+		writeJSON(w, status, resp)
+	case <-r.Context().Done():
+		// The operation still runs, its outcome ends up in the audit log.
+	}
+}
+
+func (s *Service) describe() map[string]map[string][]string {
+	description := make(map[string]map[string][]string, len(s.sensors))
+	for name, sensor := range s.sensors {
+		operations := make(map[string][]string)
+		for opName, operation := range sensor.Operations() {
+			operations[opName] = append([]string{}, operation.Args...)
+			sort.Strings(operations[opName])
+		}
+		description[name] = operations
+	}
+	return description
+}
+
+func (r *Request) Run() {
+	start := time.Now()
+	result, err := r.operation.Run(r.args)
+
+	resp := response{Sensor: r.sensor, Operation: r.name, Result: result}
+	status := http.StatusOK
+	if err != nil {
+		resp.Error = err.Error()
+		status = http.StatusInternalServerError
+		log.ErrorLog.Printf("Maintenance %s %s from %s failed: %q", r.sensor, r.name, r.remote, err)
+	}
+
+	r.service.record(auditEntry{
+		Time:      start,
+		Remote:    r.remote,
+		Sensor:    r.sensor,
+		Operation: r.name,
+		Args:      r.args,
+		Status:    status,
+		Duration:  time.Since(start).String(),
+		Result:    resp.Result,
+		Error:     resp.Error,
+	})
+	r.response <- resp
+}
+
+// record appends the entry to the audit log, or to the info log when no audit log is configured.
+func (s *Service) record(entry auditEntry) {
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+	if s.audit == nil {
+		line, _ := json.Marshal(entry)
+		log.InfoLog.Printf("Maintenance audit: %s", line)
+		return
+	}
+	if err := s.audit.Encode(entry); err != nil {
+		log.ErrorLog.Printf("Failed to write maintenance audit log: %q", err)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
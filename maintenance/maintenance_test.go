@@ -0,0 +1,151 @@
+package maintenance
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"azuremyst.org/go-home-sensors/sensors"
+)
+
+const testToken = "secret"
+
+type fakeSensor map[string]sensors.Operation
+
+func (f fakeSensor) Operations() map[string]sensors.Operation {
+	return f
+}
+
+// newTestService builds the service without registering it on the default mux, auditing to a
+// temporary file.
+func newTestService(t *testing.T) (*Service, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := &Service{
+		token: testToken,
+		sensors: map[string]sensors.Maintainable{
+			"scd4x": fakeSensor{
+				"frc": {Args: []string{"ppm"}, Run: func(args map[string]string) (string, error) {
+					return "corrected by " + args["ppm"], nil
+				}},
+			},
+		},
+		queue:     make(chan *Request, queueSize),
+		auditFile: file,
+		audit:     json.NewEncoder(file),
+	}
+	t.Cleanup(func() { service.Close() })
+	return service, path
+}
+
+func post(service *Service, path, token, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	service.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// auditEntries closes the service and reads back its audit log.
+func auditEntries(t *testing.T, service *Service, path string) []auditEntry {
+	t.Helper()
+	if err := service.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var entries []auditEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid audit entry %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestRejectedRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		body   string
+		status int
+	}{
+		{"bad token", "/maintenance/scd4x/frc", "guess", "ppm=420", http.StatusUnauthorized},
+		{"no token", "/maintenance/scd4x/frc", "", "ppm=420", http.StatusUnauthorized},
+		{"unknown sensor", "/maintenance/sen5x/frc", testToken, "ppm=420", http.StatusNotFound},
+		{"unknown operation", "/maintenance/scd4x/asc", testToken, "enabled=false", http.StatusNotFound},
+		{"missing arg", "/maintenance/scd4x/frc", testToken, "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, path := newTestService(t)
+			if recorder := post(service, tt.path, tt.token, tt.body); recorder.Code != tt.status {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.status)
+			}
+			if len(service.queue) != 0 {
+				t.Errorf("rejected request queued")
+			}
+			entries := auditEntries(t, service, path)
+			if len(entries) != 1 || entries[0].Status != tt.status || entries[0].Error == "" {
+				t.Errorf("audit = %+v, want one rejection with status %d", entries, tt.status)
+			}
+		})
+	}
+}
+
+func TestFullQueue(t *testing.T) {
+	service, path := newTestService(t)
+	for i := 0; i < queueSize; i++ {
+		service.queue <- &Request{}
+	}
+	if recorder := post(service, "/maintenance/scd4x/frc", testToken, "ppm=420"); recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusServiceUnavailable)
+	}
+	if entries := auditEntries(t, service, path); len(entries) != 1 || entries[0].Status != http.StatusServiceUnavailable {
+		t.Errorf("audit = %+v, want the rejection", entries)
+	}
+}
+
+func TestRun(t *testing.T) {
+	service, path := newTestService(t)
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- post(service, "/maintenance/scd4x/frc", testToken, "ppm=420")
+	}()
+
+	// The collection loop runs the queued request.
+	(<-service.Requests()).Run()
+	recorder := <-done
+
+	var resp response
+	if err := json.NewDecoder(recorder.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Code != http.StatusOK || resp.Result != "corrected by 420" {
+		t.Errorf("response %d %+v, want the result of frc", recorder.Code, resp)
+	}
+	entries := auditEntries(t, service, path)
+	if len(entries) != 1 || entries[0].Status != http.StatusOK || entries[0].Args["ppm"] != "420" {
+		t.Errorf("audit = %+v, want the successful frc", entries)
+	}
+}
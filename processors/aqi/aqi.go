@@ -0,0 +1,171 @@
+package aqi
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"azuremyst.org/go-home-sensors/log"
+	"azuremyst.org/go-home-sensors/processors"
+	"azuremyst.org/go-home-sensors/sensors"
+)
+
+// Pollutants, matching the sensors.ParticleConcentration metadata of the PM recordings.
+const (
+	PM2_5 = "2.5pm"
+	PM10  = "10pm"
+)
+
+// The longest averaging period used by any of the standards.
+const window = 24 * time.Hour
+
+type Config struct {
+	Enable    bool
+	Standards []string
+	NowCast   bool
+}
+
+type sample struct {
+	at    time.Time
+	value float64
+}
+
+// series holds the samples of a single pollutant, oldest first.
+type series []sample
+
+type seriesKey struct {
+	sensor    string
+	pollutant string
+}
+
+type AQIProcessor struct {
+	standards []standard
+	series    map[seriesKey]series
+	labels    map[string]map[string]string // Latest static labels per sensor
+}
+
+func CreateProcessor(conf Config) processors.Processor {
+	standards := make([]standard, 0, len(conf.Standards))
+	for _, name := range conf.Standards {
+		std, ok := newStandard(name, conf.NowCast)
+		if !ok {
+			log.ErrorLog.Fatalf("Unknown air quality index standard %q, supported: %s, %s, %s",
+				name, USEPA, EUCAQI, UKDAQI)
+		}
+		standards = append(standards, std)
+	}
+	return &AQIProcessor{standards: standards, series: make(map[seriesKey]series), labels: make(map[string]map[string]string)}
+}
+
+func (ap *AQIProcessor) Process(recordings []sensors.MeasurementRecording) []sensors.MeasurementRecording {
+	now := time.Now()
+	for _, recording := range recordings {
+		if recording.Measure.ID != sensors.ParticleMatterEnvironmental.ID {
+			continue
+		}
+		pollutant := recording.Metadata[sensors.ParticleConcentration]
+		if pollutant != PM2_5 && pollutant != PM10 {
+			continue
+		}
+		key := seriesKey{sensor: recording.Sensor, pollutant: pollutant}
+		ap.series[key] = append(ap.series[key].prune(now.Add(-window)), sample{at: now, value: recording.Value})
+		ap.labels[recording.Sensor] = recording.Labels
+	}
+
+	seen := make(map[string]bool)
+	sensorNames := make([]string, 0)
+	for key := range ap.series {
+		if !seen[key.sensor] {
+			seen[key.sensor] = true
+			sensorNames = append(sensorNames, key.sensor)
+		}
+	}
+	sort.Strings(sensorNames)
+
+	for _, sensor := range sensorNames {
+		for i := range ap.standards {
+			std := &ap.standards[i]
+			index, category, ok := ap.index(std, sensor, now)
+			if !ok {
+				continue
+			}
+			recordings = append(recordings, sensors.MeasurementRecording{
+				Measure:  &sensors.PMAirQualityIndex,
+				Value:    index,
+				Sensor:   sensor,
+				Metadata: map[sensors.Metadata]string{sensors.Standard: std.name},
+				Labels:   ap.labels[sensor],
+			})
+			// Every category is reported so the previous one is unset when the category changes.
+			for _, c := range std.categories {
+				var flag float64
+				if c == category {
+					flag = 1
+				}
+				recordings = append(recordings, sensors.MeasurementRecording{
+					Measure: &sensors.PMAirQualityCategory,
+					Value:   flag,
+					Sensor:  sensor,
+					Metadata: map[sensors.Metadata]string{
+						sensors.Standard: std.name,
+						sensors.Category: c,
+					},
+					Labels: ap.labels[sensor],
+				})
+			}
+		}
+	}
+	return recordings
+}
+
+// index is the highest sub-index over the pollutants reported by the sensor.
+func (ap *AQIProcessor) index(std *standard, sensor string, now time.Time) (float64, string, bool) {
+	found := false
+	var index float64
+	var category string
+	for _, pollutant := range []string{PM2_5, PM10} {
+		s, ok := ap.series[seriesKey{sensor: sensor, pollutant: pollutant}]
+		if !ok {
+			continue
+		}
+		subIndex, subCategory, ok := std.index(pollutant, s, now)
+		if !ok {
+			continue
+		}
+		if !found || subIndex > index {
+			index, category = subIndex, subCategory
+			found = true
+		}
+	}
+	return index, category, found
+}
+
+func (s series) prune(since time.Time) series {
+	idx := sort.Search(len(s), func(i int) bool { return !s[i].at.Before(since) })
+	return s[idx:]
+}
+
+// hourly returns the mean of each of the last n hours, the most recent hour first.
+// Hours without samples are NaN.
+func (s series) hourly(now time.Time, n int) []float64 {
+	sums := make([]float64, n)
+	counts := make([]int, n)
+	for _, smp := range s {
+		hour := int(now.Sub(smp.at) / time.Hour)
+		if hour < 0 || hour >= n {
+			continue
+		}
+		sums[hour] += smp.value
+		counts[hour]++
+	}
+
+	means := make([]float64, n)
+	for i := range means {
+		if counts[i] == 0 {
+			means[i] = math.NaN()
+		} else {
+			means[i] = sums[i] / float64(counts[i])
+		}
+	}
+	return means
+}
@@ -0,0 +1,136 @@
+package aqi
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"azuremyst.org/go-home-sensors/sensors"
+)
+
+// hourlySeries has one sample per hour over the hours, the most recent value first.
+func hourlySeries(now time.Time, values ...float64) series {
+	s := make(series, 0, len(values))
+	for i := len(values) - 1; i >= 0; i-- {
+		s = append(s, sample{at: now.Add(-time.Duration(i)*time.Hour - time.Minute), value: values[i]})
+	}
+	return s
+}
+
+func constantSeries(now time.Time, hours int, value float64) series {
+	values := make([]float64, hours)
+	for i := range values {
+		values[i] = value
+	}
+	return hourlySeries(now, values...)
+}
+
+func TestIndex(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		standard      string
+		pollutant     string
+		concentration float64
+		index         float64
+		category      string
+	}{
+		// US EPA, 24 hour means truncated to 0.1µg/m³ for PM2.5 and 1µg/m³ for PM10.
+		{USEPA, PM2_5, 0, 0, "Good"},
+		{USEPA, PM2_5, 9.09, 50, "Good"},
+		{USEPA, PM2_5, 12.0, 56, "Moderate"},
+		{USEPA, PM2_5, 35.9, 102, "Unhealthy for Sensitive Groups"},
+		{USEPA, PM2_5, 55.4, 150, "Unhealthy for Sensitive Groups"},
+		{USEPA, PM2_5, 150.5, 226, "Very Unhealthy"},
+		{USEPA, PM10, 54.9, 50, "Good"},
+		{USEPA, PM10, 158, 102, "Unhealthy for Sensitive Groups"},
+		// EU CAQI, hourly means.
+		{EUCAQI, PM2_5, 20, 33, "Low"},
+		{EUCAQI, PM2_5, 110, 100, "High"},
+		{EUCAQI, PM10, 100, 78, "High"},
+		// UK DAQI bands, 24 hour means rounded to 1µg/m³.
+		{UKDAQI, PM2_5, 11.4, 1, "Low"},
+		{UKDAQI, PM2_5, 35.4, 3, "Low"},
+		{UKDAQI, PM2_5, 35.6, 4, "Moderate"},
+		{UKDAQI, PM2_5, 70, 9, "High"},
+		{UKDAQI, PM2_5, 250, 10, "Very High"},
+		{UKDAQI, PM10, 51, 4, "Moderate"},
+		{UKDAQI, PM10, 101, 10, "Very High"},
+	}
+	for _, tt := range tests {
+		std, _ := newStandard(tt.standard, false)
+		index, category, ok := std.index(tt.pollutant, constantSeries(now, 24, tt.concentration), now)
+		if !ok || index != tt.index || category != tt.category {
+			t.Errorf("%s %s at %vµg/m³ = %v %q (ok %t), want %v %q", tt.standard, tt.pollutant, tt.concentration,
+				index, category, ok, tt.index, tt.category)
+		}
+	}
+}
+
+func TestAverages(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		average func(s series, now time.Time) (float64, bool)
+		series  series
+		want    float64
+		ok      bool
+	}{
+		// Mean of the 18 hourly means, the 6 missing hours are ignored.
+		{"24h mean", mean24h, hourlySeries(now, 10, 20, 30, 40, 10, 20, 30, 40, 10, 20, 30, 40, 10, 20, 30, 40, 10, 20), 430.0 / 18, true},
+		{"24h mean needs 18 hours", mean24h, constantSeries(now, 17, 10), 0, false},
+		{"1h mean", mean1h, hourlySeries(now, 10), 10, true},
+		{"NowCast of a stable concentration", nowCastAverage, constantSeries(now, 12, 10), 10, true},
+		// The weight min/max of 0.25 is raised to 0.5: (40 + 0.5*20 + 0.25*10) / 1.75.
+		{"NowCast of a falling concentration", nowCastAverage, hourlySeries(now, 40, 20, 10), 30, true},
+		{"NowCast needs two of the last three hours", nowCastAverage, hourlySeries(now, 40, math.NaN(), math.NaN(), 10), 0, false},
+	}
+	for _, tt := range tests {
+		s := make(series, 0, len(tt.series))
+		for _, smp := range tt.series {
+			if !math.IsNaN(smp.value) {
+				s = append(s, smp)
+			}
+		}
+		got, ok := tt.average(s, now)
+		if ok != tt.ok || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s = %v (ok %t), want %v (ok %t)", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// The category is a separate flag per category, so the previous one is unset when it changes.
+func TestProcessCategories(t *testing.T) {
+	ap := CreateProcessor(Config{Standards: []string{EUCAQI}})
+	categories := func(value float64) (float64, map[string]float64) {
+		recordings := ap.Process([]sensors.MeasurementRecording{{
+			Measure:  &sensors.ParticleMatterEnvironmental,
+			Value:    value,
+			Sensor:   "pmsa003i",
+			Metadata: map[sensors.Metadata]string{sensors.ParticleConcentration: PM2_5},
+		}})
+		var index float64
+		flags := make(map[string]float64)
+		for _, recording := range recordings {
+			switch recording.Measure.ID {
+			case sensors.PMAirQualityIndex.ID:
+				if _, ok := recording.Metadata[sensors.Category]; ok {
+					t.Errorf("index labelled with its category %q", recording.Metadata[sensors.Category])
+				}
+				index = recording.Value
+			case sensors.PMAirQualityCategory.ID:
+				flags[recording.Metadata[sensors.Category]] = recording.Value
+			}
+		}
+		return index, flags
+	}
+
+	index, flags := categories(20)
+	if index != 33 || len(flags) != 5 || flags["Low"] != 1 || flags["Very low"] != 0 {
+		t.Errorf("index %v with categories %v, want 33 with only Low set", index, flags)
+	}
+	// The hourly mean rises to 60µg/m³.
+	index, flags = categories(100)
+	if index != 77 || flags["High"] != 1 || flags["Low"] != 0 {
+		t.Errorf("index %v with categories %v, want 77 with only High set", index, flags)
+	}
+}
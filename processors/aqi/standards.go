@@ -0,0 +1,224 @@
+package aqi
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	USEPA  = "us_epa"
+	EUCAQI = "eu_caqi"
+	UKDAQI = "uk_daqi"
+)
+
+type breakpoint struct {
+	concentrationLow  float64
+	concentrationHigh float64
+	indexLow          float64
+	indexHigh         float64
+	category          string
+}
+
+type standard struct {
+	name       string
+	average    func(s series, now time.Time) (float64, bool)
+	truncate   func(pollutant string, concentration float64) float64
+	tables     map[string][]breakpoint
+	categories []string // In increasing order, shared by the pollutants
+}
+
+// US EPA breakpoints as revised in 2024.
+var epaTables = map[string][]breakpoint{
+	PM2_5: {
+		{0.0, 9.0, 0, 50, "Good"},
+		{9.1, 35.4, 51, 100, "Moderate"},
+		{35.5, 55.4, 101, 150, "Unhealthy for Sensitive Groups"},
+		{55.5, 125.4, 151, 200, "Unhealthy"},
+		{125.5, 225.4, 201, 300, "Very Unhealthy"},
+		{225.5, 325.4, 301, 500, "Hazardous"},
+	},
+	PM10: {
+		{0, 54, 0, 50, "Good"},
+		{55, 154, 51, 100, "Moderate"},
+		{155, 254, 101, 150, "Unhealthy for Sensitive Groups"},
+		{255, 354, 151, 200, "Unhealthy"},
+		{355, 424, 201, 300, "Very Unhealthy"},
+		{425, 604, 301, 500, "Hazardous"},
+	},
+}
+
+// Common Air Quality Index, hourly background grid.
+// CAQI leaves the index above 100 open ended, the "Very high" band extends the "High" band linearly.
+var caqiTables = map[string][]breakpoint{
+	PM2_5: {
+		{0, 15, 0, 25, "Very low"},
+		{15, 30, 25, 50, "Low"},
+		{30, 55, 50, 75, "Medium"},
+		{55, 110, 75, 100, "High"},
+		{110, 220, 100, 150, "Very high"},
+	},
+	PM10: {
+		{0, 25, 0, 25, "Very low"},
+		{25, 50, 25, 50, "Low"},
+		{50, 90, 50, 75, "Medium"},
+		{90, 180, 75, 100, "High"},
+		{180, 360, 100, 150, "Very high"},
+	},
+}
+
+// UK Daily Air Quality Index bands, based on the 24 hour running mean.
+// https://uk-air.defra.gov.uk/air-pollution/daqi
+var daqiTables = map[string][]breakpoint{
+	PM2_5: {
+		{0, 11, 1, 1, "Low"},
+		{12, 23, 2, 2, "Low"},
+		{24, 35, 3, 3, "Low"},
+		{36, 41, 4, 4, "Moderate"},
+		{42, 47, 5, 5, "Moderate"},
+		{48, 53, 6, 6, "Moderate"},
+		{54, 58, 7, 7, "High"},
+		{59, 64, 8, 8, "High"},
+		{65, 70, 9, 9, "High"},
+		{71, math.Inf(1), 10, 10, "Very High"},
+	},
+	PM10: {
+		{0, 16, 1, 1, "Low"},
+		{17, 33, 2, 2, "Low"},
+		{34, 50, 3, 3, "Low"},
+		{51, 58, 4, 4, "Moderate"},
+		{59, 66, 5, 5, "Moderate"},
+		{67, 75, 6, 6, "Moderate"},
+		{76, 83, 7, 7, "High"},
+		{84, 91, 8, 8, "High"},
+		{92, 100, 9, 9, "High"},
+		{101, math.Inf(1), 10, 10, "Very High"},
+	},
+}
+
+func newStandard(name string, nowCast bool) (standard, bool) {
+	var std standard
+	switch name {
+	case USEPA:
+		average := mean24h
+		if nowCast {
+			average = nowCastAverage
+		}
+		std = standard{name: name, average: average, truncate: epaTruncate, tables: epaTables}
+	case EUCAQI:
+		std = standard{name: name, average: mean1h, truncate: noTruncate, tables: caqiTables}
+	case UKDAQI:
+		std = standard{name: name, average: mean24h, truncate: roundTruncate, tables: daqiTables}
+	default:
+		return standard{}, false
+	}
+	for _, bp := range std.tables[PM2_5] {
+		if len(std.categories) == 0 || std.categories[len(std.categories)-1] != bp.category {
+			std.categories = append(std.categories, bp.category)
+		}
+	}
+	return std, true
+}
+
+// index computes the sub-index of a single pollutant.
+func (std *standard) index(pollutant string, s series, now time.Time) (float64, string, bool) {
+	table, ok := std.tables[pollutant]
+	if !ok {
+		return 0, "", false
+	}
+	concentration, ok := std.average(s, now)
+	if !ok {
+		return 0, "", false
+	}
+	concentration = std.truncate(pollutant, concentration)
+
+	for i, bp := range table {
+		if concentration > bp.concentrationHigh && i < len(table)-1 {
+			continue
+		}
+		return math.Round(bp.interpolate(concentration)), bp.category, true
+	}
+	return 0, "", false
+}
+
+func (bp *breakpoint) interpolate(concentration float64) float64 {
+	if bp.indexHigh == bp.indexLow {
+		return bp.indexLow
+	}
+	return (bp.indexHigh-bp.indexLow)/(bp.concentrationHigh-bp.concentrationLow)*(concentration-bp.concentrationLow) + bp.indexLow
+}
+
+// EPA truncates PM2.5 to one decimal and PM10 to an integer before looking up the breakpoints.
+func epaTruncate(pollutant string, concentration float64) float64 {
+	if pollutant == PM2_5 {
+		return math.Floor(concentration*10) / 10
+	}
+	return math.Floor(concentration)
+}
+
+func roundTruncate(pollutant string, concentration float64) float64 {
+	return math.Round(concentration)
+}
+
+func noTruncate(pollutant string, concentration float64) float64 {
+	return concentration
+}
+
+// mean1h is the mean of the last hour.
+func mean1h(s series, now time.Time) (float64, bool) {
+	hours := s.hourly(now, 1)
+	return hours[0], !math.IsNaN(hours[0])
+}
+
+// mean24h is the mean of the hourly means of the last day, at least 75% of the hours need data.
+func mean24h(s series, now time.Time) (float64, bool) {
+	var sum float64
+	var count int
+	for _, hour := range s.hourly(now, 24) {
+		if !math.IsNaN(hour) {
+			sum += hour
+			count++
+		}
+	}
+	if count < 18 {
+		return 0, false
+	}
+	return sum / float64(count), true
+}
+
+// nowCastAverage is the EPA NowCast for particulate matter, a weighted average of the last 12
+// hourly means that leans towards the recent hours when the concentration changes quickly.
+// Two of the three most recent hours need data.
+func nowCastAverage(s series, now time.Time) (float64, bool) {
+	hours := s.hourly(now, 12)
+
+	recent := 0
+	for _, hour := range hours[:3] {
+		if !math.IsNaN(hour) {
+			recent++
+		}
+	}
+	if recent < 2 {
+		return 0, false
+	}
+
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, hour := range hours {
+		if !math.IsNaN(hour) {
+			min = math.Min(min, hour)
+			max = math.Max(max, hour)
+		}
+	}
+	if max <= 0 {
+		return 0, true
+	}
+	weight := math.Max(min/max, 0.5)
+
+	var sum, weights float64
+	for i, hour := range hours {
+		if !math.IsNaN(hour) {
+			sum += math.Pow(weight, float64(i)) * hour
+			weights += math.Pow(weight, float64(i))
+		}
+	}
+	return sum / weights, true
+}
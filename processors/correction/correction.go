@@ -0,0 +1,128 @@
+package correction
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"azuremyst.org/go-home-sensors/log"
+	"azuremyst.org/go-home-sensors/processors"
+	"azuremyst.org/go-home-sensors/sensors"
+)
+
+const (
+	USEPA = "us_epa"
+)
+
+// Humidity readings older than this are not used for corrections.
+const maxHumidityAge = 10 * time.Minute
+
+const pm2_5 = "2.5pm"
+
+type Config struct {
+	Enable bool
+	// PM sensors to correct, the correction is applied to their PM2.5 CF=1 (standard) readings.
+	Sensors []string
+	// Sensor providing the ambient relative humidity.
+	HumiditySensor string `toml:"humidity_sensor"`
+	Method         string
+}
+
+type CorrectionProcessor struct {
+	sensors        []string
+	humiditySensor string
+	method         string
+	correct        func(pm, humidity float64) float64
+
+	humidity   float64
+	humidityAt time.Time
+}
+
+func CreateProcessor(conf Config) processors.Processor {
+	method := conf.Method
+	if method == "" {
+		method = USEPA
+	}
+
+	var correct func(pm, humidity float64) float64
+	switch method {
+	case USEPA:
+		correct = epaCorrection
+	default:
+		log.ErrorLog.Fatalf("Unknown PM correction %q, supported: %s", method, USEPA)
+	}
+	if conf.HumiditySensor == "" {
+		log.ErrorLog.Fatalf("PM correction requires a humidity_sensor")
+	}
+
+	return &CorrectionProcessor{
+		sensors:        conf.Sensors,
+		humiditySensor: conf.HumiditySensor,
+		method:         method,
+		correct:        correct,
+	}
+}
+
+func (cp *CorrectionProcessor) Process(recordings []sensors.MeasurementRecording) []sensors.MeasurementRecording {
+	now := time.Now()
+	for _, recording := range recordings {
+		if recording.Measure.ID == sensors.Humidity.ID && strings.EqualFold(recording.Sensor, cp.humiditySensor) {
+			cp.humidity = recording.Value
+			cp.humidityAt = now
+		}
+	}
+	if now.Sub(cp.humidityAt) > maxHumidityAge {
+		return recordings
+	}
+
+	corrected := make([]sensors.MeasurementRecording, 0)
+	for _, recording := range recordings {
+		if recording.Measure.ID != sensors.ParticleMatterStandard.ID ||
+			recording.Metadata[sensors.ParticleConcentration] != pm2_5 ||
+			!cp.corrects(recording.Sensor) {
+			continue
+		}
+		corrected = append(corrected, sensors.MeasurementRecording{
+			Measure: &sensors.ParticleMatterCorrected,
+			Value:   cp.correct(recording.Value, cp.humidity),
+			Sensor:  recording.Sensor,
+			Metadata: map[sensors.Metadata]string{
+				sensors.ParticleConcentration: pm2_5,
+				sensors.Correction:            cp.method,
+			},
+			Labels: recording.Labels,
+		})
+	}
+	return append(recordings, corrected...)
+}
+
+func (cp *CorrectionProcessor) corrects(sensor string) bool {
+	for _, s := range cp.sensors {
+		if strings.EqualFold(s, sensor) {
+			return true
+		}
+	}
+	return false
+}
+
+// epaCorrection is the US EPA correction for PurpleAir sensors (Barkjohn et al. 2021), extended
+// for high concentrations. pm is the Plantower CF=1 PM2.5 concentration.
+func epaCorrection(pm, humidity float64) float64 {
+	var corrected float64
+	switch {
+	case pm < 30:
+		corrected = 0.524*pm - 0.0862*humidity + 5.75
+	case pm < 50:
+		blend := pm/20 - 3.0/2
+		corrected = (0.786*blend+0.524*(1-blend))*pm - 0.0862*humidity + 5.75
+	case pm < 210:
+		corrected = 0.786*pm - 0.0862*humidity + 5.75
+	case pm < 260:
+		blend := pm/50 - 21.0/5
+		corrected = (0.69*blend+0.786*(1-blend))*pm - 0.0862*humidity*(1-blend) +
+			2.966*blend + 5.75*(1-blend) + 8.84e-4*pm*pm*blend
+	default:
+		corrected = 2.966 + 0.69*pm + 8.84e-4*pm*pm
+	}
+	return math.Max(corrected, 0)
+}
@@ -0,0 +1,70 @@
+package correction
+
+import (
+	"math"
+	"testing"
+
+	"azuremyst.org/go-home-sensors/sensors"
+)
+
+func TestEPACorrection(t *testing.T) {
+	tests := []struct {
+		pm, humidity float64
+		want         float64
+	}{
+		{10, 50, 6.68},      // 0.524*10 - 0.0862*50 + 5.75
+		{0, 90, 0},          // Negative results are clamped
+		{40, 50, 27.64},     // Halfway between the low and mid ranges, slope 0.655
+		{100, 40, 80.902},   // 0.786*100 - 0.0862*40 + 5.75
+		{235, 50, 200.0425}, // Halfway between the mid and high ranges
+		{300, 50, 289.526},  // 2.966 + 0.69*300 + 8.84e-4*300²
+	}
+	for _, tt := range tests {
+		if got := epaCorrection(tt.pm, tt.humidity); math.Abs(got-tt.want) > 1e-3 {
+			t.Errorf("epaCorrection(%v, %v) = %v, want %v", tt.pm, tt.humidity, got, tt.want)
+		}
+	}
+}
+
+// The ranges of the correction are blended so it has no jump at their boundaries.
+func TestEPACorrectionContinuity(t *testing.T) {
+	for _, boundary := range []float64{30, 50, 210, 260} {
+		below, at := epaCorrection(boundary-1e-9, 50), epaCorrection(boundary, 50)
+		if math.Abs(below-at) > 1e-6 {
+			t.Errorf("correction jumps from %v to %v at %vµg/m³", below, at, boundary)
+		}
+	}
+}
+
+func TestProcess(t *testing.T) {
+	pm := func(sensor string, measure *sensors.Measurement, concentration string, value float64) sensors.MeasurementRecording {
+		return sensors.MeasurementRecording{Measure: measure, Value: value, Sensor: sensor,
+			Metadata: map[sensors.Metadata]string{sensors.ParticleConcentration: concentration}}
+	}
+	readings := []sensors.MeasurementRecording{
+		pm("pmsa003i", &sensors.ParticleMatterStandard, pm2_5, 10),
+		pm("pmsa003i", &sensors.ParticleMatterStandard, "10pm", 20),
+		pm("pmsa003i", &sensors.ParticleMatterEnvironmental, pm2_5, 10),
+		pm("sen5x", &sensors.ParticleMatterStandard, pm2_5, 10),
+	}
+	cp := CreateProcessor(Config{Sensors: []string{"PMSA003I"}, HumiditySensor: "bme68x"})
+
+	if recordings := cp.Process(readings); len(recordings) != len(readings) {
+		t.Errorf("%d recordings without a humidity reading, want the %d readings only", len(recordings), len(readings))
+	}
+
+	humidity := sensors.MeasurementRecording{Measure: &sensors.Humidity, Value: 50, Sensor: "bme68x"}
+	recordings := cp.Process(append(readings, humidity))
+	var corrected []sensors.MeasurementRecording
+	for _, recording := range recordings {
+		if recording.Measure.ID == sensors.ParticleMatterCorrected.ID {
+			corrected = append(corrected, recording)
+		}
+	}
+	if len(corrected) != 1 {
+		t.Fatalf("%d corrected recordings, want only the PM2.5 standard one of the pmsa003i", len(corrected))
+	}
+	if corrected[0].Sensor != "pmsa003i" || math.Abs(corrected[0].Value-6.68) > 1e-9 || corrected[0].Metadata[sensors.Correction] != USEPA {
+		t.Errorf("corrected recording %+v, want 6.68 from pmsa003i with the us_epa correction", corrected[0])
+	}
+}
@@ -0,0 +1,343 @@
+package fusion
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"azuremyst.org/go-home-sensors/log"
+	"azuremyst.org/go-home-sensors/processors"
+	"azuremyst.org/go-home-sensors/sensors"
+)
+
+const (
+	Median       = "median"
+	WeightedMean = "weighted_mean"
+	Preferred    = "preferred"
+)
+
+// Scale factor turning the median absolute deviation into an estimate of the standard deviation.
+const madScale = 1.4826
+
+const defaultOutlierThreshold = 3.0
+
+type Config struct {
+	Enable bool
+	Rooms  map[string]RoomConfig
+}
+
+// RoomConfig combines the readings of Sensors into one series per measurement, reported
+// with the room as sensor name.
+type RoomConfig struct {
+	Sensors      []string
+	Measurements []string // Measurement IDs to fuse, all when empty
+	Strategy     string
+	Weights      map[string]float64 // Per sensor weight for weighted_mean, defaults to 1, one has to be positive
+	Preferred    []string           // Sensor order for preferred, falls back to Sensors order
+	// Spread between the readings, outliers included, above which the sensors are flagged as
+	// disagreeing, keyed by Measurement ID.
+	Tolerance map[string]float64
+	// Readings further than OutlierThreshold scaled median absolute deviations from the median
+	// are dropped before fusing, 3 by default. Only applied with three or more readings.
+	OutlierThreshold *float64 `toml:"outlier_threshold"`
+}
+
+type room struct {
+	name string
+	RoomConfig
+	outlierThreshold float64
+	disagreeing      map[string]bool
+}
+
+type reading struct {
+	sensor string
+	value  float64
+}
+
+type group struct {
+	recording sensors.MeasurementRecording
+	readings  []reading
+}
+
+type FusionProcessor struct {
+	rooms []*room
+}
+
+func CreateProcessor(conf Config) processors.Processor {
+	names := make([]string, 0, len(conf.Rooms))
+	for name := range conf.Rooms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rooms := make([]*room, 0, len(names))
+	for _, name := range names {
+		roomConf := conf.Rooms[name]
+		switch roomConf.Strategy {
+		case "":
+			roomConf.Strategy = Median
+		case Median, WeightedMean, Preferred:
+		default:
+			log.ErrorLog.Fatalf("Unknown fusion strategy %q for room %s, supported: %s, %s, %s",
+				roomConf.Strategy, name, Median, WeightedMean, Preferred)
+		}
+		outlierThreshold := defaultOutlierThreshold
+		if roomConf.OutlierThreshold != nil {
+			outlierThreshold = *roomConf.OutlierThreshold
+		}
+		if outlierThreshold <= 0 {
+			log.ErrorLog.Fatalf("Invalid outlier_threshold %v for room %s, must be positive", outlierThreshold, name)
+		}
+		for id, tolerance := range roomConf.Tolerance {
+			if tolerance < 0 {
+				log.ErrorLog.Fatalf("Invalid tolerance %v of %s for room %s, must not be negative", tolerance, id, name)
+			}
+		}
+		if roomConf.Strategy == WeightedMean {
+			if err := validateWeights(roomConf); err != nil {
+				log.ErrorLog.Fatalf("Invalid weights for room %s: %v", name, err)
+			}
+		}
+		rooms = append(rooms, &room{name: name, RoomConfig: roomConf, outlierThreshold: outlierThreshold, disagreeing: make(map[string]bool)})
+	}
+	return &FusionProcessor{rooms: rooms}
+}
+
+func (fp *FusionProcessor) Process(recordings []sensors.MeasurementRecording) []sensors.MeasurementRecording {
+	fused := make([]sensors.MeasurementRecording, 0)
+	for _, r := range fp.rooms {
+		fused = append(fused, r.fuse(recordings)...)
+	}
+	return append(recordings, fused...)
+}
+
+func (r *room) fuse(recordings []sensors.MeasurementRecording) []sensors.MeasurementRecording {
+	groups := make(map[string]*group)
+	keys := make([]string, 0)
+	var labels map[string]string
+	for _, recording := range recordings {
+		if !r.includes(recording) {
+			continue
+		}
+		labels = commonLabels(labels, recording.Labels)
+		key := groupKey(recording)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{recording: recording}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+		g.readings = append(g.readings, reading{sensor: recording.Sensor, value: recording.Value})
+	}
+	sort.Strings(keys)
+
+	fused := make([]sensors.MeasurementRecording, 0)
+	disagreeing := make(map[string]bool)
+	for _, key := range keys {
+		g := groups[key]
+		// The outliers count, a sensor far off is the disagreement to report.
+		if tolerance, ok := r.Tolerance[g.recording.Measure.ID]; ok {
+			disagreeing[g.recording.Measure.ID] = disagreeing[g.recording.Measure.ID] || spread(g.readings) > tolerance
+		}
+
+		readings := r.dropOutliers(g.recording.Measure.ID, g.readings)
+		if len(readings) == 0 {
+			continue
+		}
+		value := r.combine(readings)
+		if math.IsNaN(value) {
+			// Only sensors weighted 0 reported.
+			continue
+		}
+
+		recording := g.recording
+		recording.Sensor = r.name
+		recording.Value = value
+		recording.Labels = labels
+		fused = append(fused, recording)
+	}
+
+	measurementIDs := make([]string, 0, len(disagreeing))
+	for id := range disagreeing {
+		measurementIDs = append(measurementIDs, id)
+	}
+	sort.Strings(measurementIDs)
+	for _, id := range measurementIDs {
+		if disagreeing[id] != r.disagreeing[id] {
+			if disagreeing[id] {
+				log.ErrorLog.Printf("Sensors in %s disagree on %s beyond %v\n", r.name, id, r.Tolerance[id])
+			} else {
+				log.InfoLog.Printf("Sensors in %s agree again on %s\n", r.name, id)
+			}
+			r.disagreeing[id] = disagreeing[id]
+		}
+
+		var flag float64
+		if disagreeing[id] {
+			flag = 1
+		}
+		fused = append(fused, sensors.MeasurementRecording{
+			Measure:  &sensors.SensorDisagreement,
+			Value:    flag,
+			Sensor:   r.name,
+			Metadata: map[sensors.Metadata]string{sensors.MeasurementID: id},
+			Labels:   labels,
+		})
+	}
+	return fused
+}
+
+func (r *room) includes(recording sensors.MeasurementRecording) bool {
+	if len(r.Measurements) > 0 && !containsFold(r.Measurements, recording.Measure.ID) {
+		return false
+	}
+	return containsFold(r.Sensors, recording.Sensor)
+}
+
+// dropOutliers removes the readings too far away from the median, see RoomConfig.OutlierThreshold.
+func (r *room) dropOutliers(measurementID string, readings []reading) []reading {
+	if len(readings) < 3 {
+		return readings
+	}
+
+	values := make([]float64, len(readings))
+	for i, rd := range readings {
+		values[i] = rd.value
+	}
+	med := median(values)
+
+	deviations := make([]float64, len(readings))
+	for i, rd := range readings {
+		deviations[i] = math.Abs(rd.value - med)
+	}
+	limit := r.outlierThreshold * madScale * median(deviations)
+	if limit == 0 {
+		// Most readings are identical, fall back to the tolerance if there is one.
+		tolerance, ok := r.Tolerance[measurementID]
+		if !ok {
+			return readings
+		}
+		limit = tolerance
+	}
+
+	inliers := make([]reading, 0, len(readings))
+	for i, rd := range readings {
+		if deviations[i] <= limit {
+			inliers = append(inliers, rd)
+		}
+	}
+	return inliers
+}
+
+func (r *room) combine(readings []reading) float64 {
+	switch r.Strategy {
+	case WeightedMean:
+		var sum, weights float64
+		for _, rd := range readings {
+			w := weight(r.Weights, rd.sensor)
+			sum += w * rd.value
+			weights += w
+		}
+		if weights == 0 {
+			return math.NaN()
+		}
+		return sum / weights
+	case Preferred:
+		order := append(append([]string(nil), r.Preferred...), r.Sensors...)
+		for _, preferred := range order {
+			for _, rd := range readings {
+				if strings.EqualFold(preferred, rd.sensor) {
+					return rd.value
+				}
+			}
+		}
+		return readings[0].value
+	default:
+		values := make([]float64, len(readings))
+		for i, rd := range readings {
+			values[i] = rd.value
+		}
+		return median(values)
+	}
+}
+
+// weight is the weight of the sensor for weighted_mean, 1 when not configured.
+func weight(weights map[string]float64, sensor string) float64 {
+	for name, w := range weights {
+		if strings.EqualFold(name, sensor) {
+			return w
+		}
+	}
+	return 1
+}
+
+// validateWeights rejects negative weights and rooms whose sensors are all weighted 0, their mean
+// is undefined.
+func validateWeights(conf RoomConfig) error {
+	for sensor, w := range conf.Weights {
+		if w < 0 {
+			return fmt.Errorf("negative weight %v for %s", w, sensor)
+		}
+	}
+	for _, sensor := range conf.Sensors {
+		if weight(conf.Weights, sensor) > 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("the sensors are all weighted 0")
+}
+
+// commonLabels keeps the static labels shared by all fused sensors, e.g. the room but not a sensor position.
+func commonLabels(common map[string]string, labels map[string]string) map[string]string {
+	if common == nil {
+		common = make(map[string]string, len(labels))
+		for k, v := range labels {
+			common[k] = v
+		}
+		return common
+	}
+	for k, v := range common {
+		if labels[k] != v {
+			delete(common, k)
+		}
+	}
+	return common
+}
+
+func groupKey(recording sensors.MeasurementRecording) string {
+	metadata := make([]string, 0, len(recording.Metadata))
+	for k, v := range recording.Metadata {
+		metadata = append(metadata, string(k)+"="+v)
+	}
+	sort.Strings(metadata)
+	return recording.Measure.ID + "{" + strings.Join(metadata, ",") + "}"
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+func spread(readings []reading) float64 {
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, rd := range readings {
+		min = math.Min(min, rd.value)
+		max = math.Max(max, rd.value)
+	}
+	return max - min
+}
+
+func containsFold(list []string, value string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package fusion
+
+import (
+	"math"
+	"testing"
+
+	"azuremyst.org/go-home-sensors/sensors"
+)
+
+func recordings(measure *sensors.Measurement, values map[string]float64) []sensors.MeasurementRecording {
+	recordings := make([]sensors.MeasurementRecording, 0, len(values))
+	for sensor, value := range values {
+		recordings = append(recordings, sensors.MeasurementRecording{Measure: measure, Value: value, Sensor: sensor})
+	}
+	return recordings
+}
+
+func fusedValue(t *testing.T, fused []sensors.MeasurementRecording, room string, measure *sensors.Measurement) (float64, bool) {
+	t.Helper()
+	for _, recording := range fused {
+		if recording.Sensor == room && recording.Measure.ID == measure.ID {
+			return recording.Value, true
+		}
+	}
+	return 0, false
+}
+
+func TestDropOutliers(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    []float64
+		tolerance map[string]float64
+		inliers   int
+	}{
+		{"two readings are kept", []float64{20, 30}, nil, 2},
+		// Median 21, MAD 0.5, limit 3 * 1.4826 * 0.5 = 2.22.
+		{"far reading dropped", []float64{20.5, 21, 21.5, 30}, nil, 3},
+		{"within limit kept", []float64{20.5, 21, 21.5, 23}, nil, 4},
+		{"identical readings without tolerance", []float64{21, 21, 21, 30}, nil, 4},
+		{"identical readings with tolerance", []float64{21, 21, 21, 30}, map[string]float64{sensors.Temperature.ID: 1}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &room{RoomConfig: RoomConfig{Tolerance: tt.tolerance}, outlierThreshold: defaultOutlierThreshold}
+			readings := make([]reading, len(tt.values))
+			for i, value := range tt.values {
+				readings[i] = reading{value: value}
+			}
+			if inliers := r.dropOutliers(sensors.Temperature.ID, readings); len(inliers) != tt.inliers {
+				t.Errorf("dropOutliers(%v) kept %d readings, want %d", tt.values, len(inliers), tt.inliers)
+			}
+		})
+	}
+}
+
+func TestCombine(t *testing.T) {
+	values := map[string]float64{"scd4x": 20, "sen5x": 22, "bme68x": 27}
+	tests := []struct {
+		config RoomConfig
+		want   float64
+	}{
+		{RoomConfig{Strategy: Median}, 22},
+		{RoomConfig{Strategy: WeightedMean}, 23},
+		{RoomConfig{Strategy: WeightedMean, Weights: map[string]float64{"BME68X": 0, "scd4x": 3}}, 20.5},
+		{RoomConfig{Strategy: Preferred, Preferred: []string{"missing", "sen5x"}}, 22},
+		{RoomConfig{Strategy: Preferred, Sensors: []string{"bme68x", "scd4x"}}, 27},
+	}
+	for _, tt := range tests {
+		r := &room{RoomConfig: tt.config}
+		readings := make([]reading, 0, len(values))
+		for sensor, value := range values {
+			readings = append(readings, reading{sensor: sensor, value: value})
+		}
+		if got := r.combine(readings); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("combine with %+v = %v, want %v", tt.config, got, tt.want)
+		}
+	}
+}
+
+func TestProcess(t *testing.T) {
+	fp := CreateProcessor(Config{Rooms: map[string]RoomConfig{
+		"office": {Sensors: []string{"scd4x", "sen5x", "bme68x"}, Tolerance: map[string]float64{sensors.Temperature.ID: 1}},
+	}})
+	fused := fp.Process(recordings(&sensors.Temperature, map[string]float64{"scd4x": 21, "sen5x": 21.4, "bme68x": 35}))
+
+	if value, ok := fusedValue(t, fused, "office", &sensors.Temperature); !ok || value != 21.2 {
+		t.Errorf("fused temperature = %v (found %t), want the median 21.2 without the outlier", value, ok)
+	}
+	if flag, ok := fusedValue(t, fused, "office", &sensors.SensorDisagreement); !ok || flag != 1 {
+		t.Errorf("disagreement = %v (found %t), want 1 for the dropped outlier", flag, ok)
+	}
+
+	fused = fp.Process(recordings(&sensors.Temperature, map[string]float64{"scd4x": 21, "sen5x": 21.4, "bme68x": 21.8}))
+	if flag, ok := fusedValue(t, fused, "office", &sensors.SensorDisagreement); !ok || flag != 0 {
+		t.Errorf("disagreement = %v (found %t), want 0 within the tolerance", flag, ok)
+	}
+}
+
+func TestValidateWeights(t *testing.T) {
+	tests := []struct {
+		weights map[string]float64
+		valid   bool
+	}{
+		{nil, true},
+		{map[string]float64{"scd4x": 0}, true},
+		{map[string]float64{"SCD4X": 0, "sen5x": 0}, false},
+		{map[string]float64{"scd4x": -1, "sen5x": 2}, false},
+	}
+	for _, tt := range tests {
+		err := validateWeights(RoomConfig{Sensors: []string{"scd4x", "sen5x"}, Weights: tt.weights})
+		if (err == nil) != tt.valid {
+			t.Errorf("validateWeights(%v) = %v, want valid %t", tt.weights, err, tt.valid)
+		}
+	}
+}
+
+// A room whose readings are all dropped, e.g. invalid NaN readings, reports nothing instead of failing.
+func TestProcessAllOutliers(t *testing.T) {
+	for _, strategy := range []string{Median, WeightedMean, Preferred} {
+		fp := CreateProcessor(Config{Rooms: map[string]RoomConfig{
+			"office": {Sensors: []string{"a", "b", "c"}, Strategy: strategy},
+		}})
+		fused := fp.Process(recordings(&sensors.Temperature, map[string]float64{"a": math.NaN(), "b": math.NaN(), "c": math.NaN()}))
+
+		if value, ok := fusedValue(t, fused, "office", &sensors.Temperature); ok {
+			t.Errorf("%s: fused temperature %v without any inlier", strategy, value)
+		}
+		if len(fused) != 3 {
+			t.Errorf("%s: %d recordings, want the 3 readings only", strategy, len(fused))
+		}
+	}
+}
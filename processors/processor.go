@@ -0,0 +1,9 @@
+package processors
+
+import "azuremyst.org/go-home-sensors/sensors"
+
+// Processor derives additional recordings (or adjusts existing ones) after collection and
+// before the recordings are handed to the exporters.
+type Processor interface {
+	Process([]sensors.MeasurementRecording) []sensors.MeasurementRecording
+}
@@ -1,12 +1,12 @@
 package bosch
 
 import (
-	"fmt"
 	"strings"
 	"time"
 
 	"azuremyst.org/go-home-sensors/log"
 	"azuremyst.org/go-home-sensors/sensors"
+	"azuremyst.org/go-home-sensors/sensors/internal/registers"
 	"periph.io/x/conn/v3/i2c"
 )
 
@@ -217,7 +217,7 @@ const (
 )
 
 type BME68X struct {
-	device *i2c.Dev
+	registers.Registers
 
 	status     uint8
 	heatStable bool
@@ -300,7 +300,7 @@ func init() {
 }
 
 func (bme68x *BME68X) Initialize(bus i2c.Bus, addr uint16) {
-	bme68x.device = &i2c.Dev{Addr: addr, Bus: bus}
+	bme68x.Device = &i2c.Dev{Addr: addr, Bus: bus}
 	bme68x.init()
 }
 
@@ -404,7 +404,7 @@ func (bme68x *BME68X) init() {
 
 func (bme68x *BME68X) chipID() error {
 	chipID := make([]byte, 1)
-	if err := bme68x.device.Tx([]byte{BME68X_REG_CHIP_ID}, chipID); err != nil {
+	if err := bme68x.Device.Tx([]byte{BME68X_REG_CHIP_ID}, chipID); err != nil {
 		return err
 	}
 
@@ -413,7 +413,7 @@ func (bme68x *BME68X) chipID() error {
 	}
 
 	variantId := make([]byte, 1)
-	if err := bme68x.device.Tx([]byte{BME68X_REG_VARIANT_ID}, variantId); err != nil {
+	if err := bme68x.Device.Tx([]byte{BME68X_REG_VARIANT_ID}, variantId); err != nil {
 		return err
 	}
 	bme68x.variantId = variantId[0]
@@ -424,13 +424,13 @@ func (bme68x *BME68X) chipID() error {
 func (bme68x *BME68X) getCalibrationData() error {
 	coefficients := make([]byte, BME68X_LEN_COEFF_ALL)
 
-	if err := bme68x.device.Tx([]byte{BME68X_REG_COEFF1}, coefficients[0:BME68X_LEN_COEFF1]); err != nil {
+	if err := bme68x.Device.Tx([]byte{BME68X_REG_COEFF1}, coefficients[0:BME68X_LEN_COEFF1]); err != nil {
 		return err
 	}
-	if err := bme68x.device.Tx([]byte{BME68X_REG_COEFF2}, coefficients[BME68X_LEN_COEFF1:BME68X_LEN_COEFF1+BME68X_LEN_COEFF2]); err != nil {
+	if err := bme68x.Device.Tx([]byte{BME68X_REG_COEFF2}, coefficients[BME68X_LEN_COEFF1:BME68X_LEN_COEFF1+BME68X_LEN_COEFF2]); err != nil {
 		return err
 	}
-	if err := bme68x.device.Tx([]byte{BME68X_REG_COEFF3}, coefficients[BME68X_LEN_COEFF1+BME68X_LEN_COEFF2:BME68X_LEN_COEFF_ALL]); err != nil {
+	if err := bme68x.Device.Tx([]byte{BME68X_REG_COEFF3}, coefficients[BME68X_LEN_COEFF1+BME68X_LEN_COEFF2:BME68X_LEN_COEFF_ALL]); err != nil {
 		return err
 	}
 
@@ -477,20 +477,7 @@ func (bme68x *BME68X) softReset() error {
 	message := make([]byte, 2)
 	message[0] = BME68X_REG_SOFT_RESET
 	message[1] = BME68X_SOFT_RESET_CMD
-	return bme68x.device.Tx(message, []byte{})
-}
-
-func (bme68x *BME68X) setBits(register uint8, mask uint8, pos uint8, value uint8) error {
-	temp, err := bme68x.readRegs(register, 1)
-	if err != nil {
-		return fmt.Errorf("failed to read bits %v %v", register, err)
-	}
-	temp[0] &= ^mask
-	temp[0] |= value << pos
-	if err = bme68x.setRegs([]byte{register, temp[0]}); err != nil {
-		return fmt.Errorf("failed to set bits %v %v", register, err)
-	}
-	return nil
+	return bme68x.Device.Tx(message, []byte{})
 }
 
 func (bme68x *BME68X) addGasData() {
@@ -500,28 +487,13 @@ func (bme68x *BME68X) addGasData() {
 	}
 }
 
-func (bme68x *BME68X) readRegs(reg uint8, length uint8) ([]byte, error) {
-	response := make([]byte, length)
-	if err := bme68x.device.Tx([]byte{reg}, response); err != nil {
-		return response, fmt.Errorf("failed to read registry %v %v", reg, err)
-	}
-	return response, nil
-}
-
-func (bme68x *BME68X) setRegs(b []byte) error {
-	if err := bme68x.device.Tx(b, []byte{}); err != nil {
-		return fmt.Errorf("failed to write command %v %v", b[0], err)
-	}
-	return nil
-}
-
 func (bme68x *BME68X) setHumidityOversample(oversample uint8) error {
 	bme68x.tphSettings.osHum = oversample
-	return bme68x.setBits(BME68X_REG_CTRL_HUM, BME68X_OSH_MSK, 0, oversample)
+	return bme68x.SetBits(BME68X_REG_CTRL_HUM, BME68X_OSH_MSK, 0, oversample)
 }
 
 func (bme68x *BME68X) getHumidityOversample() (uint8, error) {
-	data, err := bme68x.readRegs(BME68X_REG_CTRL_HUM, 1)
+	data, err := bme68x.ReadRegs(BME68X_REG_CTRL_HUM, 1)
 	if err != nil {
 		return data[0], err
 	}
@@ -530,11 +502,11 @@ func (bme68x *BME68X) getHumidityOversample() (uint8, error) {
 
 func (bme68x *BME68X) setPressureOversample(oversample uint8) error {
 	bme68x.tphSettings.osPres = oversample
-	return bme68x.setBits(BME68X_REG_CTRL_MEAS, BME68X_OSP_MSK, BME68X_OSP_POS, oversample)
+	return bme68x.SetBits(BME68X_REG_CTRL_MEAS, BME68X_OSP_MSK, BME68X_OSP_POS, oversample)
 }
 
 func (bme68x *BME68X) getPressureOversample() (uint8, error) {
-	data, err := bme68x.readRegs(BME68X_REG_CTRL_MEAS, 1)
+	data, err := bme68x.ReadRegs(BME68X_REG_CTRL_MEAS, 1)
 	if err != nil {
 		return data[0], err
 	}
@@ -543,11 +515,11 @@ func (bme68x *BME68X) getPressureOversample() (uint8, error) {
 
 func (bme68x *BME68X) setTemperatureOversample(oversample uint8) error {
 	bme68x.tphSettings.osTemp = oversample
-	return bme68x.setBits(BME68X_REG_CTRL_MEAS, BME68X_OST_MSK, BME68X_OST_POS, oversample)
+	return bme68x.SetBits(BME68X_REG_CTRL_MEAS, BME68X_OST_MSK, BME68X_OST_POS, oversample)
 }
 
 func (bme68x *BME68X) getTemperatureOversample() (uint8, error) {
-	data, err := bme68x.readRegs(BME68X_REG_CTRL_MEAS, 1)
+	data, err := bme68x.ReadRegs(BME68X_REG_CTRL_MEAS, 1)
 	if err != nil {
 		return data[0], err
 	}
@@ -556,11 +528,11 @@ func (bme68x *BME68X) getTemperatureOversample() (uint8, error) {
 
 func (bme68x *BME68X) setFilter(size uint8) error {
 	bme68x.tphSettings.filter = size
-	return bme68x.setBits(BME68X_REG_CONFIG, BME68X_FILTER_MSK, BME68X_FILTER_POS, size)
+	return bme68x.SetBits(BME68X_REG_CONFIG, BME68X_FILTER_MSK, BME68X_FILTER_POS, size)
 }
 
 func (bme68x *BME68X) getFilter() (uint8, error) {
-	data, err := bme68x.readRegs(BME68X_REG_CONFIG, 1)
+	data, err := bme68x.ReadRegs(BME68X_REG_CONFIG, 1)
 	if err != nil {
 		return data[0], err
 	}
@@ -573,11 +545,11 @@ func (bme68x *BME68X) setGasStatus() error {
 	} else {
 		bme68x.gasSettings.enable = BME68X_ENABLE_GAS_MEAS_L
 	}
-	return bme68x.setBits(BME68X_REG_CTRL_GAS_1, BME68X_RUN_GAS_MSK, BME68X_RUN_GAS_POS, bme68x.gasSettings.enable)
+	return bme68x.SetBits(BME68X_REG_CTRL_GAS_1, BME68X_RUN_GAS_MSK, BME68X_RUN_GAS_POS, bme68x.gasSettings.enable)
 }
 
 func (bme68x *BME68X) getGasStatus() (uint8, error) {
-	data, err := bme68x.readRegs(BME68X_REG_CTRL_GAS_1, 1)
+	data, err := bme68x.ReadRegs(BME68X_REG_CTRL_GAS_1, 1)
 	if err != nil {
 		return data[0], err
 	}
@@ -589,7 +561,7 @@ func (bme68x *BME68X) setPowerMode(mode uint8) error {
 		return err
 	}
 	if bme68x.status != mode {
-		if err := bme68x.setBits(BME68X_REG_CTRL_MEAS, BME68X_MODE_MSK, 0, mode); err != nil {
+		if err := bme68x.SetBits(BME68X_REG_CTRL_MEAS, BME68X_MODE_MSK, 0, mode); err != nil {
 			return err
 		}
 
@@ -609,7 +581,7 @@ func (bme68x *BME68X) setPowerMode(mode uint8) error {
 }
 
 func (bme68x *BME68X) getPowerMode() error {
-	data, err := bme68x.readRegs(BME68X_REG_CTRL_MEAS, 1)
+	data, err := bme68x.ReadRegs(BME68X_REG_CTRL_MEAS, 1)
 	if err != nil {
 		return err
 	}
@@ -624,7 +596,7 @@ func (bme68x *BME68X) getSensorData() {
 	}
 
 	for i := 0; i < 10; i++ {
-		status, err := bme68x.readRegs(BME68X_REG_FIELD0, 1)
+		status, err := bme68x.ReadRegs(BME68X_REG_FIELD0, 1)
 		if err != nil {
 			log.ErrorLog.Printf("Could not read status; %v\n", err)
 			return
@@ -635,7 +607,7 @@ func (bme68x *BME68X) getSensorData() {
 			continue
 		}
 
-		regs, err := bme68x.readRegs(BME68X_REG_FIELD0, BME68X_LEN_FIELD)
+		regs, err := bme68x.ReadRegs(BME68X_REG_FIELD0, BME68X_LEN_FIELD)
 		if err != nil {
 			log.ErrorLog.Printf("Could not read data; %+v\n", err)
 			return
@@ -834,7 +806,7 @@ func (bme68x *BME68X) calcGasResistanceHigh(gas_res_adc uint16, gas_range uint8)
 func (bme68x *BME68X) SetGasHeaterTemperature(temperature uint16) error {
 	bme68x.gasSettings.heatr_temp = temperature
 	temp := bme68x.calcHeaterTemperature(bme68x.gasSettings.heatr_temp)
-	return bme68x.setRegs([]byte{BME68X_REG_RES_HEAT0, temp})
+	return bme68x.SetRegs([]byte{BME68X_REG_RES_HEAT0, temp})
 }
 
 // Convert raw heater resistance using calibration data
@@ -851,7 +823,7 @@ func (bme68x *BME68X) calcHeaterTemperature(temp uint16) uint8 {
 func (bme68x *BME68X) SetGasHeaterDuration(duration uint16) error {
 	bme68x.gasSettings.heatr_dur = duration
 	temp := bme68x.calcHeaterDuration(bme68x.gasSettings.heatr_dur)
-	return bme68x.setRegs([]byte{BME68X_REG_GAS_WAIT0, temp})
+	return bme68x.SetRegs([]byte{BME68X_REG_GAS_WAIT0, temp})
 }
 
 func (bme68x *BME68X) calcHeaterDuration(duration uint16) uint8 {
@@ -871,7 +843,7 @@ func (bme68x *BME68X) calcHeaterDuration(duration uint16) uint8 {
 }
 
 func (bme68x *BME68X) getGasHeaterProfile() (uint8, error) {
-	data, err := bme68x.readRegs(BME68X_REG_CTRL_GAS_1, 1)
+	data, err := bme68x.ReadRegs(BME68X_REG_CTRL_GAS_1, 1)
 	if err != nil {
 		return data[0], err
 	}
@@ -880,5 +852,5 @@ func (bme68x *BME68X) getGasHeaterProfile() (uint8, error) {
 }
 
 func (bme68x *BME68X) SetGasHeaterProfile(profile uint8) error {
-	return bme68x.setBits(BME68X_REG_CTRL_GAS_1, BME68X_NBCONV_MSK, 0, profile)
+	return bme68x.SetBits(BME68X_REG_CTRL_GAS_1, BME68X_NBCONV_MSK, 0, profile)
 }
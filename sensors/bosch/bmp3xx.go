@@ -0,0 +1,255 @@
+package bosch
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"azuremyst.org/go-home-sensors/log"
+	"azuremyst.org/go-home-sensors/sensors"
+	"azuremyst.org/go-home-sensors/sensors/internal/registers"
+	"periph.io/x/conn/v3/i2c"
+)
+
+const (
+	BMP388_CHIP_ID = uint8(0x50)
+	BMP390_CHIP_ID = uint8(0x60)
+)
+
+// Register address in i2c
+const (
+	BMP3_REG_CHIP_ID  = uint8(0x00)
+	BMP3_REG_ERR      = uint8(0x02)
+	BMP3_REG_STATUS   = uint8(0x03)
+	BMP3_REG_DATA     = uint8(0x04) // Pressure then temperature, 24 bits little endian each
+	BMP3_REG_PWR_CTRL = uint8(0x1B)
+	BMP3_REG_OSR      = uint8(0x1C)
+	BMP3_REG_CONFIG   = uint8(0x1F)
+	BMP3_REG_CALIB    = uint8(0x31)
+	BMP3_REG_CMD      = uint8(0x7E)
+)
+
+const (
+	BMP3_LEN_CALIB = 21
+	BMP3_LEN_DATA  = 6
+
+	BMP3_SOFT_RESET_CMD = uint8(0xB6)
+	BMP3_PRESS_EN       = uint8(0x01)
+	BMP3_TEMP_EN        = uint8(0x02)
+	BMP3_FORCED_MODE    = uint8(0x10)
+	BMP3_DRDY_MSK       = uint8(0x60) // Pressure and temperature data ready
+	BMP3_ERR_MSK        = uint8(0x07)
+	BMP3_FILTER_MSK     = uint8(0x0E)
+	BMP3_FILTER_POS     = uint8(1)
+)
+
+var (
+	bmp3Oversamplings = []uint8{1, 2, 4, 8, 16, 32}
+	bmp3Filters       = []uint8{0, 1, 3, 7, 15, 31, 63, 127}
+)
+
+// BMP3XXConfig holds the options of the [sensors.bmp388] and [sensors.bmp390] sections.
+type BMP3XXConfig struct {
+	// Oversampling of each measurement: 1 (default), 2, 4, 8, 16 or 32 times. Higher ones reduce
+	// the noise but take longer.
+	TemperatureOversampling uint8 `toml:"temperature_oversampling"`
+	PressureOversampling    uint8 `toml:"pressure_oversampling"`
+	// Coefficient of the IIR filter smoothing short disturbances: 0 (off, default), 1, 3, 7, 15,
+	// 31, 63 or 127.
+	IIRFilter uint8 `toml:"iir_filter"`
+}
+
+// BMP3XXCalibrationData holds the coefficients converted to floating point as in the datasheet.
+type BMP3XXCalibrationData struct {
+	parT1, parT2, parT3                                                           float64
+	parP1, parP2, parP3, parP4, parP5, parP6, parP7, parP8, parP9, parP10, parP11 float64
+}
+
+// BMP3XX reads the BMP388 and BMP390 barometers, told apart by their chip ID.
+type BMP3XX struct {
+	registers.Registers
+
+	config    BMP3XXConfig
+	osTemp    uint8 // Register values of the settings
+	osPres    uint8
+	filter    uint8
+	chipID    uint8
+	calibData BMP3XXCalibrationData
+
+	Temperature float64 // Celsius degrees
+	Pressure    float64 // hPa
+}
+
+func init() {
+	sensors.RegisterSensor(&BMP3XX{})
+}
+
+func (bmp3 *BMP3XX) Configure(decode func(v interface{}) error) error {
+	if err := decode(&bmp3.config); err != nil {
+		return err
+	}
+
+	var err error
+	if bmp3.osTemp, err = encodeSetting("temperature oversampling", defaultOversampling(bmp3.config.TemperatureOversampling), bmp3Oversamplings); err != nil {
+		return err
+	}
+	if bmp3.osPres, err = encodeSetting("pressure oversampling", defaultOversampling(bmp3.config.PressureOversampling), bmp3Oversamplings); err != nil {
+		return err
+	}
+	bmp3.filter, err = encodeSetting("IIR filter", bmp3.config.IIRFilter, bmp3Filters)
+	return err
+}
+
+func (bmp3 *BMP3XX) Initialize(bus i2c.Bus, addr uint16) {
+	bmp3.Device = &i2c.Dev{Addr: addr, Bus: bus}
+
+	if err := bmp3.SetRegs([]byte{BMP3_REG_CMD, BMP3_SOFT_RESET_CMD}); err != nil {
+		log.ErrorLog.Printf("Failed to reset device: %q", err)
+		return
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	chipID, err := bmp3.ChipID(BMP3_REG_CHIP_ID, BMP388_CHIP_ID, BMP390_CHIP_ID)
+	if err != nil {
+		log.ErrorLog.Printf("Failed to identify the BMP3xx: %q", err)
+		return
+	}
+	bmp3.chipID = chipID
+
+	if err := bmp3.getCalibrationData(); err != nil {
+		log.ErrorLog.Printf("Failed to read calibration data: %q", err)
+		bmp3.chipID = 0
+		return
+	}
+	if err := bmp3.SetRegs([]byte{BMP3_REG_OSR, bmp3.osTemp<<3 | bmp3.osPres}); err != nil {
+		log.ErrorLog.Printf("Failed to set oversampling: %q", err)
+	}
+	if err := bmp3.SetBits(BMP3_REG_CONFIG, BMP3_FILTER_MSK, BMP3_FILTER_POS, bmp3.filter); err != nil {
+		log.ErrorLog.Printf("Failed to set IIR filter: %q", err)
+	}
+
+	log.InfoLog.Printf("Bosch %s\n\tChipID: 0x%02x\n\tOversampling: T x%d, P x%d\n\tIIRFilter: %d", strings.ToUpper(bmp3.Name()),
+		bmp3.chipID, bmp3Oversamplings[bmp3.osTemp], bmp3Oversamplings[bmp3.osPres], bmp3Filters[bmp3.filter])
+}
+
+// Name is the variant found by Initialize.
+func (bmp3 *BMP3XX) Name() string {
+	if bmp3.chipID == BMP390_CHIP_ID {
+		return "bmp390"
+	}
+	return "bmp388"
+}
+
+func (bmp3 *BMP3XX) Family(name string) bool {
+	return strings.EqualFold(name, "bmp388") || strings.EqualFold(name, "bmp390") || strings.EqualFold(name, "bmp3xx")
+}
+
+func (bmp3 *BMP3XX) Collect() []sensors.MeasurementRecording {
+	if bmp3.chipID == 0 {
+		return nil
+	}
+	if err := bmp3.getSensorData(); err != nil {
+		log.ErrorLog.Printf("Failed to measure: %q", err)
+		return nil
+	}
+
+	measurements := make([]sensors.MeasurementRecording, 0)
+	measurements = append(measurements, sensors.MeasurementRecording{
+		Measure: &sensors.Temperature,
+		Value:   bmp3.Temperature,
+		Sensor:  bmp3.Name(),
+	})
+	measurements = append(measurements, sensors.MeasurementRecording{
+		Measure: &sensors.Pressure,
+		Value:   bmp3.Pressure,
+		Sensor:  bmp3.Name(),
+	})
+	return measurements
+}
+
+func (bmp3 *BMP3XX) getCalibrationData() error {
+	coefficients, err := bmp3.ReadRegs(BMP3_REG_CALIB, BMP3_LEN_CALIB)
+	if err != nil {
+		return err
+	}
+	word := func(i int) uint16 {
+		return binary.LittleEndian.Uint16(coefficients[i : i+2])
+	}
+
+	bmp3.calibData.parT1 = float64(word(0)) * math.Pow(2, 8)
+	bmp3.calibData.parT2 = float64(word(2)) / math.Pow(2, 30)
+	bmp3.calibData.parT3 = float64(int8(coefficients[4])) / math.Pow(2, 48)
+	bmp3.calibData.parP1 = (float64(int16(word(5))) - math.Pow(2, 14)) / math.Pow(2, 20)
+	bmp3.calibData.parP2 = (float64(int16(word(7))) - math.Pow(2, 14)) / math.Pow(2, 29)
+	bmp3.calibData.parP3 = float64(int8(coefficients[9])) / math.Pow(2, 32)
+	bmp3.calibData.parP4 = float64(int8(coefficients[10])) / math.Pow(2, 37)
+	bmp3.calibData.parP5 = float64(word(11)) * math.Pow(2, 3)
+	bmp3.calibData.parP6 = float64(word(13)) / math.Pow(2, 6)
+	bmp3.calibData.parP7 = float64(int8(coefficients[15])) / math.Pow(2, 8)
+	bmp3.calibData.parP8 = float64(int8(coefficients[16])) / math.Pow(2, 15)
+	bmp3.calibData.parP9 = float64(int16(word(17))) / math.Pow(2, 48)
+	bmp3.calibData.parP10 = float64(int8(coefficients[19])) / math.Pow(2, 48)
+	bmp3.calibData.parP11 = float64(int8(coefficients[20])) / math.Pow(2, 65)
+	return nil
+}
+
+// getSensorData runs a forced measurement and compensates the readings.
+func (bmp3 *BMP3XX) getSensorData() error {
+	if err := bmp3.SetRegs([]byte{BMP3_REG_PWR_CTRL, BMP3_PRESS_EN | BMP3_TEMP_EN | BMP3_FORCED_MODE}); err != nil {
+		return err
+	}
+
+	// Measurement time from the datasheet, in µs
+	wait := 234 + 392 + 2020*float64(bmp3Oversamplings[bmp3.osPres]) + 163 + 2020*float64(bmp3Oversamplings[bmp3.osTemp])
+	time.Sleep(time.Duration(wait) * time.Microsecond)
+	ready := false
+	for i := 0; i < 10 && !ready; i++ {
+		status, err := bmp3.ReadRegs(BMP3_REG_STATUS, 1)
+		if err != nil {
+			return err
+		}
+		if ready = status[0]&BMP3_DRDY_MSK == BMP3_DRDY_MSK; !ready {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if !ready {
+		errorReg, err := bmp3.ReadRegs(BMP3_REG_ERR, 1)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("no data ready, error register 0x%02x", errorReg[0]&BMP3_ERR_MSK)
+	}
+
+	regs, err := bmp3.ReadRegs(BMP3_REG_DATA, BMP3_LEN_DATA)
+	if err != nil {
+		return err
+	}
+	adcPres := uint32(regs[2])<<16 | uint32(regs[1])<<8 | uint32(regs[0])
+	adcTemp := uint32(regs[5])<<16 | uint32(regs[4])<<8 | uint32(regs[3])
+
+	bmp3.Temperature = bmp3.computeTemperature(adcTemp)
+	bmp3.Pressure = bmp3.computePressure(adcPres, bmp3.Temperature) / 100
+	return nil
+}
+
+// Compensation formulas in floating point from the datasheet.
+func (bmp3 *BMP3XX) computeTemperature(adcTemp uint32) float64 {
+	c := bmp3.calibData
+	partialData1 := float64(adcTemp) - c.parT1
+	partialData2 := partialData1 * c.parT2
+	return partialData2 + partialData1*partialData1*c.parT3
+}
+
+// computePressure returns the pressure in Pa.
+func (bmp3 *BMP3XX) computePressure(adcPres uint32, temperature float64) float64 {
+	c := bmp3.calibData
+	t := temperature
+	uP := float64(adcPres)
+
+	partialOut1 := c.parP5 + c.parP6*t + c.parP7*t*t + c.parP8*t*t*t
+	partialOut2 := uP * (c.parP1 + c.parP2*t + c.parP3*t*t + c.parP4*t*t*t)
+	partialData4 := uP*uP*(c.parP9+c.parP10*t) + uP*uP*uP*c.parP11
+	return partialOut1 + partialOut2 + partialData4
+}
@@ -0,0 +1,106 @@
+package bosch
+
+import (
+	"math"
+	"testing"
+
+	"periph.io/x/conn/v3/i2c"
+)
+
+// bmp3Registers are the calibration registers of a BMP388, as stored in the NVM.
+type bmp3Registers struct {
+	t1, t2   uint16
+	t3       int8
+	p1, p2   int16
+	p3, p4   int8
+	p5, p6   uint16
+	p7, p8   int8
+	p9       int16
+	p10, p11 int8
+}
+
+var bmp388NVM = bmp3Registers{
+	t1: 27712, t2: 19159, t3: -7,
+	p1: -3158, p2: -4567, p3: 35, p4: 1, p5: 24290, p6: 30463, p7: 3, p8: -6, p9: 17133, p10: 14, p11: -60,
+}
+
+func newFakeBMP388(nvm bmp3Registers) *BMP3XX {
+	bus := &fakeRegisterBus{}
+	calib := BMP3_REG_CALIB
+	bus.putWords(calib, int(nvm.t1), int(nvm.t2))
+	bus.registers[calib+4] = byte(nvm.t3)
+	bus.putWords(calib+5, int(nvm.p1), int(nvm.p2))
+	bus.registers[calib+9], bus.registers[calib+10] = byte(nvm.p3), byte(nvm.p4)
+	bus.putWords(calib+11, int(nvm.p5), int(nvm.p6))
+	bus.registers[calib+15], bus.registers[calib+16] = byte(nvm.p7), byte(nvm.p8)
+	bus.putWords(calib+17, int(nvm.p9))
+	bus.registers[calib+19], bus.registers[calib+20] = byte(nvm.p10), byte(nvm.p11)
+
+	bmp388 := &BMP3XX{chipID: BMP388_CHIP_ID}
+	bmp388.Device = &i2c.Dev{Addr: 0x77, Bus: bus}
+	return bmp388
+}
+
+// The floating point compensation of the datasheet matches the fixed point one of the Bosch
+// BMP3 sensor API, from the registers on.
+func TestBMP3XXCompensation(t *testing.T) {
+	bmp388 := newFakeBMP388(bmp388NVM)
+	if err := bmp388.getCalibrationData(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, adcTemp := range []uint32{8000000, 8383000, 8700000} {
+		wantTemperature, tLin := bmp3TemperatureReference(bmp388NVM, adcTemp)
+		temperature := bmp388.computeTemperature(adcTemp)
+		if math.Abs(temperature-wantTemperature) > 0.01 {
+			t.Errorf("temperature of %d = %v, want %v", adcTemp, temperature, wantTemperature)
+		}
+
+		for _, adcPressure := range []uint32{5000000, 5490000, 6000000} {
+			wantPressure := bmp3PressureReference(bmp388NVM, adcPressure, tLin)
+			if pressure := bmp388.computePressure(adcPressure, temperature); math.Abs(pressure-wantPressure) > 1 {
+				t.Errorf("pressure of %d at %.2f°C = %v, want %v", adcPressure, temperature, pressure, wantPressure)
+			}
+		}
+	}
+}
+
+// bmp3TemperatureReference is the fixed point temperature compensation of the Bosch BMP3 sensor
+// API, it returns the temperature in °C and t_lin for the pressure compensation.
+func bmp3TemperatureReference(nvm bmp3Registers, adcTemp uint32) (float64, int64) {
+	partialData1 := int64(adcTemp) - 256*int64(nvm.t1)
+	partialData2 := int64(nvm.t2) * partialData1
+	partialData3 := partialData1 * partialData1
+	partialData4 := partialData3 * int64(nvm.t3)
+	partialData5 := partialData2*262144 + partialData4
+	tLin := partialData5 / 4294967296
+	return float64(tLin*25/16384) / 100, tLin
+}
+
+// bmp3PressureReference is the fixed point pressure compensation of the Bosch BMP3 sensor API, in Pa.
+func bmp3PressureReference(nvm bmp3Registers, adcPressure uint32, tLin int64) float64 {
+	uP := int64(adcPressure)
+	partialData1 := tLin * tLin
+	partialData2 := partialData1 / 64
+	partialData3 := partialData2 * tLin / 256
+	partialData4 := int64(nvm.p8) * partialData3 / 32
+	partialData5 := int64(nvm.p7) * partialData1 * 16
+	partialData6 := int64(nvm.p6) * tLin * 4194304
+	offset := int64(nvm.p5)*140737488355328 + partialData4 + partialData5 + partialData6
+
+	partialData2 = int64(nvm.p4) * partialData3 / 32
+	partialData4 = int64(nvm.p3) * partialData1 * 4
+	partialData5 = (int64(nvm.p2) - 16384) * tLin * 2097152
+	sensitivity := (int64(nvm.p1)-16384)*70368744177664 + partialData2 + partialData4 + partialData5
+
+	partialData1 = sensitivity / 16777216 * uP
+	partialData2 = int64(nvm.p10) * tLin
+	partialData3 = partialData2 + 65536*int64(nvm.p9)
+	partialData4 = partialData3 * uP / 8192
+	partialData5 = uP * (partialData4 / 10) / 512 * 10
+	partialData6 = uP * uP
+	partialData2 = int64(nvm.p11) * partialData6 / 65536
+	partialData3 = partialData2 * uP / 128
+	partialData4 = offset/4 + partialData1 + partialData5 + partialData3
+	return float64(uint64(partialData4)*25/1099511627776) / 100
+}
@@ -0,0 +1,344 @@
+package bosch
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"azuremyst.org/go-home-sensors/log"
+	"azuremyst.org/go-home-sensors/sensors"
+	"azuremyst.org/go-home-sensors/sensors/internal/registers"
+	"periph.io/x/conn/v3/i2c"
+)
+
+// Chip IDs, the BMP280 samples used 0x56 and 0x57
+const (
+	BMP280_CHIP_ID = uint8(0x58)
+	BME280_CHIP_ID = uint8(0x60)
+)
+
+// Register address in i2c
+const (
+	BMX280_REG_CALIB_TP   = uint8(0x88) // Temperature and pressure coefficients
+	BMX280_REG_CALIB_H1   = uint8(0xA1)
+	BMX280_REG_CHIP_ID    = uint8(0xD0)
+	BMX280_REG_SOFT_RESET = uint8(0xE0)
+	BMX280_REG_CALIB_H2   = uint8(0xE1) // Remaining humidity coefficients
+	BMX280_REG_CTRL_HUM   = uint8(0xF2) // Only applied after writing CTRL_MEAS
+	BMX280_REG_STATUS     = uint8(0xF3)
+	BMX280_REG_CTRL_MEAS  = uint8(0xF4)
+	BMX280_REG_CONFIG     = uint8(0xF5)
+	BMX280_REG_DATA       = uint8(0xF7)
+)
+
+const (
+	BMX280_LEN_CALIB_TP = 24
+	BMX280_LEN_CALIB_H2 = 7
+	BMX280_LEN_DATA     = 8 // Pressure, temperature and humidity
+
+	BMX280_SOFT_RESET_CMD = uint8(0xB6)
+	BMX280_FORCED_MODE    = uint8(0x01)
+	BMX280_MEASURING_MSK  = uint8(0x08)
+	BMX280_FILTER_MSK     = uint8(0x1C)
+	BMX280_FILTER_POS     = uint8(2)
+	BMX280_OSH_MSK        = uint8(0x07)
+)
+
+var (
+	bmx280Oversamplings = []uint8{0, 1, 2, 4, 8, 16} // Indexed by register value, 0 skips the measurement
+	bmx280Filters       = []uint8{0, 2, 4, 8, 16}
+)
+
+// BMX280Config holds the options of the [sensors.bme280] and [sensors.bmp280] sections. The
+// defaults are the weather monitoring settings recommended by Bosch.
+type BMX280Config struct {
+	// Oversampling of each measurement: 1 (default), 2, 4, 8 or 16 times. Higher ones reduce the
+	// noise but take longer.
+	TemperatureOversampling uint8 `toml:"temperature_oversampling"`
+	PressureOversampling    uint8 `toml:"pressure_oversampling"`
+	HumidityOversampling    uint8 `toml:"humidity_oversampling"` // BME280 only
+	// Coefficient of the IIR filter smoothing short disturbances, e.g. slammed doors: 0 (off,
+	// default), 2, 4, 8 or 16.
+	IIRFilter uint8 `toml:"iir_filter"`
+}
+
+type BMX280CalibrationData struct {
+	digT1 uint16
+	digT2 int16
+	digT3 int16
+
+	digP1 uint16
+	digP2 int16
+	digP3 int16
+	digP4 int16
+	digP5 int16
+	digP6 int16
+	digP7 int16
+	digP8 int16
+	digP9 int16
+
+	digH1 uint8
+	digH2 int16
+	digH3 uint8
+	digH4 int16
+	digH5 int16
+	digH6 int8
+}
+
+// BMX280 reads the BMP280 barometer and the BME280, which adds humidity. The chip ID tells them
+// apart.
+type BMX280 struct {
+	registers.Registers
+
+	config    BMX280Config
+	osTemp    uint8 // Register values of the settings
+	osPres    uint8
+	osHum     uint8
+	filter    uint8
+	chipID    uint8
+	calibData BMX280CalibrationData
+
+	Temperature float64 // Celsius degrees
+	Pressure    float64 // hPa
+	Humidity    float64 // % relative humidity
+}
+
+func init() {
+	sensors.RegisterSensor(&BMX280{})
+}
+
+func (bmx280 *BMX280) Configure(decode func(v interface{}) error) error {
+	if err := decode(&bmx280.config); err != nil {
+		return err
+	}
+
+	var err error
+	if bmx280.osTemp, err = encodeSetting("temperature oversampling", defaultOversampling(bmx280.config.TemperatureOversampling), bmx280Oversamplings); err != nil {
+		return err
+	}
+	if bmx280.osPres, err = encodeSetting("pressure oversampling", defaultOversampling(bmx280.config.PressureOversampling), bmx280Oversamplings); err != nil {
+		return err
+	}
+	if bmx280.osHum, err = encodeSetting("humidity oversampling", defaultOversampling(bmx280.config.HumidityOversampling), bmx280Oversamplings); err != nil {
+		return err
+	}
+	if bmx280.filter, err = encodeSetting("IIR filter", bmx280.config.IIRFilter, bmx280Filters); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (bmx280 *BMX280) Initialize(bus i2c.Bus, addr uint16) {
+	bmx280.Device = &i2c.Dev{Addr: addr, Bus: bus}
+
+	if err := bmx280.SetRegs([]byte{BMX280_REG_SOFT_RESET, BMX280_SOFT_RESET_CMD}); err != nil {
+		log.ErrorLog.Printf("Failed to reset device: %q", err)
+		return
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	chipID, err := bmx280.ChipID(BMX280_REG_CHIP_ID, BMP280_CHIP_ID, 0x56, 0x57, BME280_CHIP_ID)
+	if err != nil {
+		log.ErrorLog.Printf("Failed to identify the BMP280/BME280: %q", err)
+		return
+	}
+	bmx280.chipID = chipID
+
+	if err := bmx280.getCalibrationData(); err != nil {
+		log.ErrorLog.Printf("Failed to read calibration data: %q", err)
+		bmx280.chipID = 0
+		return
+	}
+	if err := bmx280.SetBits(BMX280_REG_CONFIG, BMX280_FILTER_MSK, BMX280_FILTER_POS, bmx280.filter); err != nil {
+		log.ErrorLog.Printf("Failed to set IIR filter: %q", err)
+	}
+
+	log.InfoLog.Printf("Bosch %s\n\tChipID: 0x%02x\n\tOversampling: T x%d, P x%d, H x%d\n\tIIRFilter: %d", strings.ToUpper(bmx280.Name()),
+		bmx280.chipID, bmx280Oversamplings[bmx280.osTemp], bmx280Oversamplings[bmx280.osPres], bmx280Oversamplings[bmx280.osHum],
+		bmx280Filters[bmx280.filter])
+}
+
+// Name is the variant found by Initialize.
+func (bmx280 *BMX280) Name() string {
+	if bmx280.chipID != 0 && bmx280.chipID != BME280_CHIP_ID {
+		return "bmp280"
+	}
+	return "bme280"
+}
+
+func (bmx280 *BMX280) Family(name string) bool {
+	return strings.EqualFold(name, "bme280") || strings.EqualFold(name, "bmp280")
+}
+
+func (bmx280 *BMX280) hasHumidity() bool {
+	return bmx280.chipID == BME280_CHIP_ID
+}
+
+func (bmx280 *BMX280) Collect() []sensors.MeasurementRecording {
+	if bmx280.chipID == 0 {
+		return nil
+	}
+	if err := bmx280.getSensorData(); err != nil {
+		log.ErrorLog.Printf("Failed to measure: %q", err)
+		return nil
+	}
+
+	measurements := make([]sensors.MeasurementRecording, 0)
+	measurements = append(measurements, sensors.MeasurementRecording{
+		Measure: &sensors.Temperature,
+		Value:   bmx280.Temperature,
+		Sensor:  bmx280.Name(),
+	})
+	measurements = append(measurements, sensors.MeasurementRecording{
+		Measure: &sensors.Pressure,
+		Value:   bmx280.Pressure,
+		Sensor:  bmx280.Name(),
+	})
+	if bmx280.hasHumidity() {
+		measurements = append(measurements, sensors.MeasurementRecording{
+			Measure: &sensors.Humidity,
+			Value:   bmx280.Humidity,
+			Sensor:  bmx280.Name(),
+		})
+	}
+	return measurements
+}
+
+func (bmx280 *BMX280) getCalibrationData() error {
+	coefficients, err := bmx280.ReadRegs(BMX280_REG_CALIB_TP, BMX280_LEN_CALIB_TP)
+	if err != nil {
+		return err
+	}
+	word := func(i int) uint16 {
+		return binary.LittleEndian.Uint16(coefficients[i : i+2])
+	}
+
+	bmx280.calibData.digT1 = word(0)
+	bmx280.calibData.digT2 = int16(word(2))
+	bmx280.calibData.digT3 = int16(word(4))
+	bmx280.calibData.digP1 = word(6)
+	bmx280.calibData.digP2 = int16(word(8))
+	bmx280.calibData.digP3 = int16(word(10))
+	bmx280.calibData.digP4 = int16(word(12))
+	bmx280.calibData.digP5 = int16(word(14))
+	bmx280.calibData.digP6 = int16(word(16))
+	bmx280.calibData.digP7 = int16(word(18))
+	bmx280.calibData.digP8 = int16(word(20))
+	bmx280.calibData.digP9 = int16(word(22))
+
+	if !bmx280.hasHumidity() {
+		return nil
+	}
+	h1, err := bmx280.ReadRegs(BMX280_REG_CALIB_H1, 1)
+	if err != nil {
+		return err
+	}
+	h, err := bmx280.ReadRegs(BMX280_REG_CALIB_H2, BMX280_LEN_CALIB_H2)
+	if err != nil {
+		return err
+	}
+	bmx280.calibData.digH1 = h1[0]
+	bmx280.calibData.digH2 = int16(binary.LittleEndian.Uint16(h[0:2]))
+	bmx280.calibData.digH3 = h[2]
+	// H4 and H5 are 12 bit values sharing the nibbles of 0xE5
+	bmx280.calibData.digH4 = int16(int8(h[3]))<<4 | int16(h[4]&0x0F)
+	bmx280.calibData.digH5 = int16(int8(h[5]))<<4 | int16(h[4]>>4)
+	bmx280.calibData.digH6 = int8(h[6])
+	return nil
+}
+
+// getSensorData runs a forced measurement and compensates the readings.
+func (bmx280 *BMX280) getSensorData() error {
+	if bmx280.hasHumidity() {
+		if err := bmx280.SetBits(BMX280_REG_CTRL_HUM, BMX280_OSH_MSK, 0, bmx280.osHum); err != nil {
+			return err
+		}
+	}
+	if err := bmx280.SetRegs([]byte{BMX280_REG_CTRL_MEAS, bmx280.osTemp<<5 | bmx280.osPres<<2 | BMX280_FORCED_MODE}); err != nil {
+		return err
+	}
+
+	// Maximum measurement time from the datasheet, in µs
+	wait := 1250 + 2300*float64(bmx280Oversamplings[bmx280.osTemp]) + 2300*float64(bmx280Oversamplings[bmx280.osPres]) + 575
+	if bmx280.hasHumidity() {
+		wait += 2300*float64(bmx280Oversamplings[bmx280.osHum]) + 575
+	}
+	time.Sleep(time.Duration(wait) * time.Microsecond)
+	for i := 0; i < 10; i++ {
+		status, err := bmx280.ReadRegs(BMX280_REG_STATUS, 1)
+		if err != nil {
+			return err
+		}
+		if status[0]&BMX280_MEASURING_MSK == 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	regs, err := bmx280.ReadRegs(BMX280_REG_DATA, BMX280_LEN_DATA)
+	if err != nil {
+		return err
+	}
+	adcPres := int32(regs[0])<<12 | int32(regs[1])<<4 | int32(regs[2])>>4
+	adcTemp := int32(regs[3])<<12 | int32(regs[4])<<4 | int32(regs[5])>>4
+	adcHum := int32(regs[6])<<8 | int32(regs[7])
+	if adcTemp == 0x80000 {
+		return fmt.Errorf("no temperature measured")
+	}
+
+	tFine := bmx280.computeTemperature(adcTemp)
+	bmx280.Temperature = tFine / 5120
+	bmx280.Pressure = bmx280.computePressure(adcPres, tFine) / 100
+	if bmx280.hasHumidity() {
+		bmx280.Humidity = bmx280.computeHumidity(adcHum, tFine)
+	}
+	return nil
+}
+
+// Compensation formulas in floating point from the datasheet, computeTemperature returns t_fine
+// which the other compensations depend on.
+func (bmx280 *BMX280) computeTemperature(adcTemp int32) float64 {
+	c := bmx280.calibData
+	var1 := (float64(adcTemp)/16384 - float64(c.digT1)/1024) * float64(c.digT2)
+	var2 := float64(adcTemp)/131072 - float64(c.digT1)/8192
+	var2 = var2 * var2 * float64(c.digT3)
+	return var1 + var2
+}
+
+// computePressure returns the pressure in Pa.
+func (bmx280 *BMX280) computePressure(adcPres int32, tFine float64) float64 {
+	c := bmx280.calibData
+	var1 := tFine/2 - 64000
+	var2 := var1 * var1 * float64(c.digP6) / 32768
+	var2 = var2 + var1*float64(c.digP5)*2
+	var2 = var2/4 + float64(c.digP4)*65536
+	var1 = (float64(c.digP3)*var1*var1/524288 + float64(c.digP2)*var1) / 524288
+	var1 = (1 + var1/32768) * float64(c.digP1)
+	if var1 == 0 {
+		return 0 // Avoid a division by zero
+	}
+	pressure := 1048576 - float64(adcPres)
+	pressure = (pressure - var2/4096) * 6250 / var1
+	var1 = float64(c.digP9) * pressure * pressure / 2147483648
+	var2 = pressure * float64(c.digP8) / 32768
+	return pressure + (var1+var2+float64(c.digP7))/16
+}
+
+func (bmx280 *BMX280) computeHumidity(adcHum int32, tFine float64) float64 {
+	c := bmx280.calibData
+	humidity := tFine - 76800
+	humidity = (float64(adcHum) - (float64(c.digH4)*64 + float64(c.digH5)/16384*humidity)) *
+		(float64(c.digH2) / 65536 * (1 + float64(c.digH6)/67108864*humidity*(1+float64(c.digH3)/67108864*humidity)))
+	humidity = humidity * (1 - float64(c.digH1)*humidity/524288)
+	return math.Min(math.Max(humidity, 0), 100)
+}
+
+// defaultOversampling replaces the unset oversampling, skipping measurements is not supported.
+func defaultOversampling(oversampling uint8) uint8 {
+	if oversampling == 0 {
+		return 1
+	}
+	return oversampling
+}
@@ -0,0 +1,104 @@
+package bosch
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"periph.io/x/conn/v3/i2c"
+	"periph.io/x/conn/v3/physic"
+)
+
+// fakeRegisterBus emulates the register file of a chip, a read starts at the register written
+// before it and continues with the next ones.
+type fakeRegisterBus struct {
+	registers [256]byte
+}
+
+func (f *fakeRegisterBus) String() string {
+	return "fake registers"
+}
+
+func (f *fakeRegisterBus) SetSpeed(physic.Frequency) error {
+	return nil
+}
+
+func (f *fakeRegisterBus) Tx(addr uint16, w, r []byte) error {
+	copy(f.registers[w[0]+1:], w[1:])
+	copy(r, f.registers[w[0]:])
+	return nil
+}
+
+// putWords stores the words little endian from the register on.
+func (f *fakeRegisterBus) putWords(register uint8, words ...int) {
+	for i, word := range words {
+		binary.LittleEndian.PutUint16(f.registers[int(register)+2*i:], uint16(word))
+	}
+}
+
+// Calibration of the compensation example of the BMP280 datasheet, section 8.1, with the humidity
+// coefficients of a BME280.
+var bmx280Calibration = BMX280CalibrationData{
+	digT1: 27504, digT2: 26435, digT3: -1000,
+	digP1: 36477, digP2: -10685, digP3: 3024, digP4: 2855, digP5: 140, digP6: -7, digP7: 15500, digP8: -14600, digP9: 6000,
+	digH1: 75, digH2: 362, digH3: 0, digH4: 313, digH5: 50, digH6: 30,
+}
+
+func newFakeBME280() *BMX280 {
+	bus := &fakeRegisterBus{}
+	c := bmx280Calibration
+	bus.putWords(BMX280_REG_CALIB_TP, int(c.digT1), int(c.digT2), int(c.digT3), int(c.digP1), int(c.digP2),
+		int(c.digP3), int(c.digP4), int(c.digP5), int(c.digP6), int(c.digP7), int(c.digP8), int(c.digP9))
+	bus.registers[BMX280_REG_CALIB_H1] = c.digH1
+	bus.putWords(BMX280_REG_CALIB_H2, int(c.digH2))
+	// H4 and H5 share the nibbles of 0xE5.
+	copy(bus.registers[BMX280_REG_CALIB_H2+2:], []byte{c.digH3, 0x13, 0x29, 0x03, byte(c.digH6)})
+
+	bme280 := &BMX280{chipID: BME280_CHIP_ID}
+	bme280.Device = &i2c.Dev{Addr: 0x76, Bus: bus}
+	return bme280
+}
+
+func TestBMX280CalibrationData(t *testing.T) {
+	bme280 := newFakeBME280()
+	if err := bme280.getCalibrationData(); err != nil {
+		t.Fatal(err)
+	}
+	if bme280.calibData != bmx280Calibration {
+		t.Errorf("calibration = %+v, want %+v", bme280.calibData, bmx280Calibration)
+	}
+}
+
+func TestBMX280Compensation(t *testing.T) {
+	bme280 := newFakeBME280()
+	if err := bme280.getCalibrationData(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Datasheet example: 25.08°C and 100653.27 Pa.
+	tFine := bme280.computeTemperature(519888)
+	if temperature := tFine / 5120; math.Abs(temperature-25.08) > 0.005 {
+		t.Errorf("temperature = %v, want 25.08", temperature)
+	}
+	if pressure := bme280.computePressure(415148, tFine); math.Abs(pressure-100653.27) > 0.01 {
+		t.Errorf("pressure = %v, want 100653.27", pressure)
+	}
+
+	for _, adcHum := range []int32{24000, 26000, 29000, 32000, 35000} {
+		want := bme280HumidityReference(bme280.calibData, adcHum, int32(tFine))
+		if humidity := bme280.computeHumidity(adcHum, tFine); math.Abs(humidity-want) > 0.01 {
+			t.Errorf("humidity of %d = %v, want %v", adcHum, humidity, want)
+		}
+	}
+}
+
+// bme280HumidityReference is the fixed point compensation of the BME280 datasheet, section 4.2.3.
+func bme280HumidityReference(c BMX280CalibrationData, adcHum int32, tFine int32) float64 {
+	v := tFine - 76800
+	offset := (adcHum<<14 - int32(c.digH4)<<20 - int32(c.digH5)*v + 16384) >> 15
+	sensitivity := ((((v*int32(c.digH6))>>10)*(((v*int32(c.digH3))>>11)+32768))>>10 + 2097152) * int32(c.digH2)
+	v = offset * ((sensitivity + 8192) >> 14)
+	v = v - (((((v >> 15) * (v >> 15)) >> 7) * int32(c.digH1)) >> 4)
+	v = int32(math.Min(math.Max(float64(v), 0), 419430400))
+	return float64(v>>12) / 1024
+}
@@ -0,0 +1,16 @@
+package bosch
+
+import (
+	"fmt"
+)
+
+// encodeSetting returns the register value of an oversampling or filter setting, its index in
+// the values supported by the chip.
+func encodeSetting(name string, value uint8, supported []uint8) (uint8, error) {
+	for code, v := range supported {
+		if v == value {
+			return uint8(code), nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported %s %d, supported: %v", name, value, supported)
+}
@@ -0,0 +1,97 @@
+package sensors
+
+import "fmt"
+
+// Calibration corrects the readings of a single measurement of a sensor.
+type Calibration struct {
+	Offset float64
+	Scale  float64 // Defaults to 1
+	// Polynomial coefficients, lowest order first. Replaces Offset and Scale when set.
+	Polynomial []float64
+	// Also report the uncalibrated value as the measurement with the "_raw" suffix.
+	KeepRaw bool `toml:"keep_raw"`
+}
+
+// uncalibrated holds the "_raw" measurements of the calibrations keeping the raw value, keyed by
+// the ID of the calibrated measurement.
+var uncalibrated = make(map[string]*Measurement)
+
+func (c *Calibration) Apply(value float64) float64 {
+	if len(c.Polynomial) > 0 {
+		var result float64
+		for i := len(c.Polynomial) - 1; i >= 0; i-- {
+			result = result*value + c.Polynomial[i]
+		}
+		return result
+	}
+
+	scale := c.Scale
+	if scale == 0 {
+		scale = 1
+	}
+	return value*scale + c.Offset
+}
+
+// RegisterCalibrations checks that every calibration targets a known measurement and adds the
+// "_raw" measurement of the ones keeping the raw value to Measurements. It has to be called before
+// the exporters register the measurements.
+func RegisterCalibrations(calibrations map[string]Calibration) error {
+	for id, calibration := range calibrations {
+		measurement := calibratable(id)
+		if measurement == nil {
+			return fmt.Errorf("unknown measurement %q", id)
+		}
+		if !calibration.KeepRaw || uncalibrated[id] != nil {
+			continue
+		}
+		raw := &Measurement{
+			ID:          id + "_raw",
+			Description: measurement.Description + " (uncalibrated)",
+			Unit:        measurement.Unit,
+			Labels:      measurement.Labels,
+		}
+		uncalibrated[id] = raw
+		Measurements = append(Measurements, *raw)
+	}
+	return nil
+}
+
+// calibratable returns the measurement of the ID, nil when unknown or a "_raw" measurement.
+func calibratable(id string) *Measurement {
+	for _, raw := range uncalibrated {
+		if raw.ID == id {
+			return nil
+		}
+	}
+	for i := range Measurements {
+		if Measurements[i].ID == id {
+			return &Measurements[i]
+		}
+	}
+	return nil
+}
+
+// Calibrate applies the calibration matching the measurement ID of each recording.
+func Calibrate(recordings []MeasurementRecording, calibrations map[string]Calibration) []MeasurementRecording {
+	if len(calibrations) == 0 {
+		return recordings
+	}
+
+	calibrated := make([]MeasurementRecording, 0, len(recordings))
+	for _, recording := range recordings {
+		calibration, ok := calibrations[recording.Measure.ID]
+		if !ok {
+			calibrated = append(calibrated, recording)
+			continue
+		}
+
+		if calibration.KeepRaw {
+			raw := recording
+			raw.Measure = uncalibrated[recording.Measure.ID]
+			calibrated = append(calibrated, raw)
+		}
+		recording.Value = calibration.Apply(recording.Value)
+		calibrated = append(calibrated, recording)
+	}
+	return calibrated
+}
@@ -0,0 +1,58 @@
+// Package registers gives access to the registers of the sensors with an I²C register layout: the
+// register address is written first, then its value is read or written. Several consecutive
+// registers are read or written at once.
+package registers
+
+import (
+	"fmt"
+
+	"periph.io/x/conn/v3/i2c"
+)
+
+type Registers struct {
+	Device *i2c.Dev
+}
+
+// SetBits replaces the bits of the register under the mask by the value shifted to pos.
+func (r *Registers) SetBits(register uint8, mask uint8, pos uint8, value uint8) error {
+	temp, err := r.ReadRegs(register, 1)
+	if err != nil {
+		return fmt.Errorf("failed to read bits %v %v", register, err)
+	}
+	temp[0] &= ^mask
+	temp[0] |= value << pos
+	if err = r.SetRegs([]byte{register, temp[0]}); err != nil {
+		return fmt.Errorf("failed to set bits %v %v", register, err)
+	}
+	return nil
+}
+
+func (r *Registers) ReadRegs(reg uint8, length uint8) ([]byte, error) {
+	response := make([]byte, length)
+	if err := r.Device.Tx([]byte{reg}, response); err != nil {
+		return response, fmt.Errorf("failed to read registry %v %v", reg, err)
+	}
+	return response, nil
+}
+
+// SetRegs writes the register address followed by the values of it and the next registers.
+func (r *Registers) SetRegs(b []byte) error {
+	if err := r.Device.Tx(b, []byte{}); err != nil {
+		return fmt.Errorf("failed to write command %v %v", b[0], err)
+	}
+	return nil
+}
+
+// ChipID reads the chip ID register and checks it is one of the expected IDs.
+func (r *Registers) ChipID(reg uint8, expected ...uint8) (uint8, error) {
+	chipID, err := r.ReadRegs(reg, 1)
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range expected {
+		if chipID[0] == id {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("unexpected chip ID 0x%02x", chipID[0])
+}
@@ -14,6 +14,11 @@ const (
 	MicrogramsPerCubicMetre Unit = "MicrogramsPerCubicMetre" // µg/m³
 	VOCIndex                Unit = "VOC Index"               // Range 1 - 500
 	NOxIndex                Unit = "NOx Index"               // Range 1 - 500
+	Flag                    Unit = "Flag"                    // 0 or 1
+	Ticks                   Unit = "Ticks"                   // Raw sensor signal
+	MetresPerSecondSquared  Unit = "MetresPerSecondSquared"  // m/s²
+	DegreesPerSecond        Unit = "DegreesPerSecond"        // dps
+	Microtesla              Unit = "Microtesla"              // µT
 )
 
 type Metadata string
@@ -22,6 +27,13 @@ const (
 	SensorName            Metadata = "sensor"
 	ParticleSize          Metadata = "particleSize"
 	ParticleConcentration Metadata = "particleConcentration"
+	Standard              Metadata = "standard"
+	Category              Metadata = "category"
+	MeasurementID         Metadata = "measurement"
+	Correction            Metadata = "correction"
+	Status                Metadata = "status"
+	Gas                   Metadata = "gas"
+	Axis                  Metadata = "axis"
 )
 
 type Measurement struct {
@@ -92,6 +104,36 @@ var (
 		Unit:        MicrogramsPerCubicMetre,
 		Labels:      []string{string(ParticleConcentration), string(SensorName)},
 	}
+	ParticleMatterCorrected = Measurement{
+		ID:          "room_air_quality_pm_concentration_corrected",
+		Description: "Air quality. PM concentration corrected for the ambient humidity.",
+		Unit:        MicrogramsPerCubicMetre,
+		Labels:      []string{string(ParticleConcentration), string(Correction), string(SensorName)},
+	}
+	UncompensatedHumidity = Measurement{
+		ID:          "room_humidity_uncompensated",
+		Description: "Relative humidity before the compensation for the heat of the device",
+		Unit:        Percentage,
+		Labels:      []string{string(SensorName)},
+	}
+	UncompensatedTemperature = Measurement{
+		ID:          "room_temperature_uncompensated",
+		Description: "Temperature in C before the compensation for the heat of the device",
+		Unit:        Celsius,
+		Labels:      []string{string(SensorName)},
+	}
+	GasRawSignal = Measurement{
+		ID:          "room_gas_raw_signal",
+		Description: "Raw signal of a gas sensor, the input of the VOC and NOx index algorithms",
+		Unit:        Ticks,
+		Labels:      []string{string(Gas), string(SensorName)},
+	}
+	TypicalParticleSize = Measurement{
+		ID:          "room_air_quality_typical_particle_size",
+		Description: "Air quality. Typical size of the particles in um.",
+		Unit:        Micrometre,
+		Labels:      []string{string(SensorName)},
+	}
 	ParticleCount = Measurement{
 		ID:          "room_air_quality_particles_count",
 		Description: "Air quality. Particulate matter per 0.1L air.",
@@ -99,8 +141,82 @@ var (
 		Labels:      []string{string(ParticleSize), string(SensorName)},
 	}
 
+	PMAirQualityIndex = Measurement{
+		ID:          "room_air_quality_index",
+		Description: "Air quality index computed from PM concentrations.",
+		Unit:        AirQualityIndex,
+		Labels:      []string{string(Standard), string(SensorName)},
+	}
+	PMAirQualityCategory = Measurement{
+		ID:          "room_air_quality_index_category",
+		Description: "Set for the current category of the air quality index, unset for the other categories of the standard.",
+		Unit:        Flag,
+		Labels:      []string{string(Standard), string(Category), string(SensorName)},
+	}
+
+	SensorDisagreement = Measurement{
+		ID:          "room_sensor_disagreement",
+		Description: "Set when the sensors fused into a room metric disagree beyond the configured tolerance.",
+		Unit:        Flag,
+		Labels:      []string{string(MeasurementID), string(SensorName)},
+	}
+
+	SensorStatus = Measurement{
+		ID:          "sensor_status",
+		Description: "Set while the sensor reports the status condition, e.g. a fan failure.",
+		Unit:        Flag,
+		Labels:      []string{string(Status), string(SensorName)},
+	}
+
+	BadFrames = Measurement{
+		ID:          "sensor_bad_frames",
+		Description: "Frames dropped for a bad start, length or checksum since the start.",
+		Unit:        Count,
+		Labels:      []string{string(SensorName)},
+	}
+
+	Acceleration = Measurement{
+		ID:          "sensor_acceleration",
+		Description: "Acceleration of the sensor along the axis in m/s², including gravity.",
+		Unit:        MetresPerSecondSquared,
+		Labels:      []string{string(Axis), string(SensorName)},
+	}
+	AngularRate = Measurement{
+		ID:          "sensor_angular_rate",
+		Description: "Rotation rate of the sensor around the axis in dps.",
+		Unit:        DegreesPerSecond,
+		Labels:      []string{string(Axis), string(SensorName)},
+	}
+	AccelerationRMS = Measurement{
+		ID:          "sensor_acceleration_rms",
+		Description: "Root mean square of the acceleration along the axis around its mean over the collection interval in m/s², e.g. vibrations.",
+		Unit:        MetresPerSecondSquared,
+		Labels:      []string{string(Axis), string(SensorName)},
+	}
+	AccelerationPeakToPeak = Measurement{
+		ID:          "sensor_acceleration_peak_to_peak",
+		Description: "Difference between the highest and lowest acceleration along the axis over the collection interval in m/s², e.g. shocks.",
+		Unit:        MetresPerSecondSquared,
+		Labels:      []string{string(Axis), string(SensorName)},
+	}
+	AngularRatePeak = Measurement{
+		ID:          "sensor_angular_rate_peak",
+		Description: "Highest rotation rate of the sensor around the axis over the collection interval in dps, e.g. a door swing.",
+		Unit:        DegreesPerSecond,
+		Labels:      []string{string(Axis), string(SensorName)},
+	}
+	MagneticField = Measurement{
+		ID:          "sensor_magnetic_field",
+		Description: "Magnetic field along the axis in µT.",
+		Unit:        Microtesla,
+		Labels:      []string{string(Axis), string(SensorName)},
+	}
+
 	Measurements = []Measurement{Pressure, Temperature, Humidity, CarbonDioxide, AIQ, GasResistance,
-		ParticleCount, ParticleMatterEnvironmental, ParticleMatterStandard, NOx, VOC}
+		ParticleCount, ParticleMatterEnvironmental, ParticleMatterStandard, ParticleMatterCorrected, NOx, VOC,
+		PMAirQualityIndex, PMAirQualityCategory, SensorDisagreement, SensorStatus, UncompensatedHumidity, UncompensatedTemperature,
+		GasRawSignal, TypicalParticleSize, BadFrames, Acceleration, AngularRate, MagneticField, AccelerationRMS,
+		AccelerationPeakToPeak, AngularRatePeak}
 )
 
 type MeasurementRecording struct {
@@ -108,4 +224,5 @@ type MeasurementRecording struct {
 	Value    float64
 	Sensor   string
 	Metadata map[Metadata]string
+	Labels   map[string]string // Static labels from the config, e.g. room or host
 }
@@ -0,0 +1,258 @@
+package plantower
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"azuremyst.org/go-home-sensors/log"
+	"azuremyst.org/go-home-sensors/sensors"
+	"periph.io/x/conn/v3/i2c"
+)
+
+// The Plantower sensors share the same 32 byte frame over I²C and UART.
+const (
+	frameSize   = 32
+	frameLength = 28 // Length field, the bytes following it
+)
+
+// Bad frames are read again this many times before giving up for the cycle.
+const frameRetries = 2
+
+var frameStart = []byte{0x42, 0x4D}
+
+// Frame holds the values of a data frame, concentrations in µg/m³ and counts per 0.1L air.
+type Frame struct {
+	PM1Standard    uint16
+	PM2_5Standard  uint16
+	PM10Standard   uint16
+	PM1Env         uint16
+	PM2_5Env       uint16
+	PM10Env        uint16
+	Particles0_3um uint16
+	Particles0_5um uint16
+	Particles1um   uint16
+	Particles2_5um uint16
+	Particles5um   uint16
+	Particles10um  uint16
+	Version        uint8
+	ErrorCode      uint8 // 0 when the sensor works fine
+}
+
+// transport delivers the raw frames of a sensor.
+type transport interface {
+	readFrame() ([]byte, error)
+}
+
+type i2cTransport struct {
+	device *i2c.Dev
+}
+
+func (t *i2cTransport) readFrame() ([]byte, error) {
+	response := make([]byte, frameSize)
+	if err := t.device.Tx(nil, response); err != nil {
+		return nil, fmt.Errorf("error while reading from device: %v", err)
+	}
+	return response, nil
+}
+
+// frameReader reads validated frames from a transport and keeps track of the bad ones.
+type frameReader struct {
+	transport transport
+	badFrames uint64
+	errorCode uint8
+}
+
+// read returns the next valid frame, reading again when a frame is bad.
+func (r *frameReader) read(name string) (Frame, error) {
+	var err error
+	for attempt := 0; attempt <= frameRetries; attempt++ {
+		var data []byte
+		if data, err = r.transport.readFrame(); err != nil {
+			continue
+		}
+		var frame Frame
+		if frame, err = decodeFrame(data); err != nil {
+			r.badFrames++
+			log.ErrorLog.Printf("%s: dropped bad frame % x: %v", name, data, err)
+			continue
+		}
+
+		if frame.ErrorCode != r.errorCode {
+			if frame.ErrorCode != 0 {
+				log.ErrorLog.Printf("%s reports error code 0x%02x", name, frame.ErrorCode)
+			} else {
+				log.InfoLog.Printf("%s cleared error code 0x%02x", name, r.errorCode)
+			}
+			r.errorCode = frame.ErrorCode
+		}
+		return frame, nil
+	}
+	return Frame{}, err
+}
+
+// recordings reports the health of the sensor next to the readings.
+func (r *frameReader) recordings(sensor string) []sensors.MeasurementRecording {
+	var errorFlag float64
+	if r.errorCode != 0 {
+		errorFlag = 1
+	}
+	return []sensors.MeasurementRecording{
+		{
+			Measure: &sensors.BadFrames,
+			Value:   float64(r.badFrames),
+			Sensor:  sensor,
+		},
+		{
+			Measure:  &sensors.SensorStatus,
+			Value:    errorFlag,
+			Sensor:   sensor,
+			Metadata: map[sensors.Metadata]string{sensors.Status: "error"},
+		},
+	}
+}
+
+// decodeFrame finds the first valid frame in data, resynchronizing on the start bytes when the
+// data does not begin with a frame or a frame is corrupted.
+func decodeFrame(data []byte) (Frame, error) {
+	err := errors.New("no frame start found")
+	for start := 0; start+len(frameStart) <= len(data); start++ {
+		if data[start] != frameStart[0] || data[start+1] != frameStart[1] {
+			continue
+		}
+		if start+frameSize > len(data) {
+			err = errors.New("truncated frame")
+			break
+		}
+		frame, parseErr := parseFrame(data[start : start+frameSize])
+		if parseErr == nil {
+			return frame, nil
+		}
+		err = parseErr
+	}
+	return Frame{}, err
+}
+
+// parseFrame validates the frame length and checksum and decodes the values.
+func parseFrame(data []byte) (Frame, error) {
+	if len(data) != frameSize || binary.BigEndian.Uint16(data[2:4]) != frameLength {
+		return Frame{}, fmt.Errorf("invalid PM2.5 frame length")
+	}
+	if err := crc(data); err != nil {
+		return Frame{}, err
+	}
+
+	word := func(i int) uint16 {
+		return binary.BigEndian.Uint16(data[i : i+2])
+	}
+	return Frame{
+		PM1Standard:    word(4),
+		PM2_5Standard:  word(6),
+		PM10Standard:   word(8),
+		PM1Env:         word(10),
+		PM2_5Env:       word(12),
+		PM10Env:        word(14),
+		Particles0_3um: word(16),
+		Particles0_5um: word(18),
+		Particles1um:   word(20),
+		Particles2_5um: word(22),
+		Particles5um:   word(24),
+		Particles10um:  word(26),
+		Version:        data[28],
+		ErrorCode:      data[29],
+	}, nil
+}
+
+func crc(data []byte) error {
+	checksum := binary.BigEndian.Uint16(data[30:32])
+	check := sumUINT8(data[0:30])
+	if check != checksum {
+		return fmt.Errorf("invalid PM2.5 checksum")
+	}
+	return nil
+}
+
+func sumUINT8(array []uint8) uint16 {
+	var result uint16
+	for _, v := range array {
+		result += uint16(v)
+	}
+	return result
+}
+
+func (frame *Frame) recordings(sensor string) []sensors.MeasurementRecording {
+	measurements := make([]sensors.MeasurementRecording, 0)
+	measurements = append(measurements, sensors.MeasurementRecording{
+		Measure:  &sensors.ParticleMatterStandard,
+		Value:    float64(frame.PM1Standard),
+		Sensor:   sensor,
+		Metadata: map[sensors.Metadata]string{sensors.ParticleConcentration: "1.0pm"},
+	})
+	measurements = append(measurements, sensors.MeasurementRecording{
+		Measure:  &sensors.ParticleMatterStandard,
+		Value:    float64(frame.PM2_5Standard),
+		Sensor:   sensor,
+		Metadata: map[sensors.Metadata]string{sensors.ParticleConcentration: "2.5pm"},
+	})
+	measurements = append(measurements, sensors.MeasurementRecording{
+		Measure:  &sensors.ParticleMatterStandard,
+		Value:    float64(frame.PM10Standard),
+		Sensor:   sensor,
+		Metadata: map[sensors.Metadata]string{sensors.ParticleConcentration: "10pm"},
+	})
+	measurements = append(measurements, sensors.MeasurementRecording{
+		Measure:  &sensors.ParticleMatterEnvironmental,
+		Value:    float64(frame.PM1Env),
+		Sensor:   sensor,
+		Metadata: map[sensors.Metadata]string{sensors.ParticleConcentration: "1.0pm"},
+	})
+	measurements = append(measurements, sensors.MeasurementRecording{
+		Measure:  &sensors.ParticleMatterEnvironmental,
+		Value:    float64(frame.PM2_5Env),
+		Sensor:   sensor,
+		Metadata: map[sensors.Metadata]string{sensors.ParticleConcentration: "2.5pm"},
+	})
+	measurements = append(measurements, sensors.MeasurementRecording{
+		Measure:  &sensors.ParticleMatterEnvironmental,
+		Value:    float64(frame.PM10Env),
+		Sensor:   sensor,
+		Metadata: map[sensors.Metadata]string{sensors.ParticleConcentration: "10pm"},
+	})
+	measurements = append(measurements, sensors.MeasurementRecording{
+		Measure:  &sensors.ParticleCount,
+		Value:    float64(frame.Particles0_3um),
+		Sensor:   sensor,
+		Metadata: map[sensors.Metadata]string{sensors.ParticleSize: "0.3um"},
+	})
+	measurements = append(measurements, sensors.MeasurementRecording{
+		Measure:  &sensors.ParticleCount,
+		Value:    float64(frame.Particles0_5um),
+		Sensor:   sensor,
+		Metadata: map[sensors.Metadata]string{sensors.ParticleSize: "0.5um"},
+	})
+	measurements = append(measurements, sensors.MeasurementRecording{
+		Measure:  &sensors.ParticleCount,
+		Value:    float64(frame.Particles1um),
+		Sensor:   sensor,
+		Metadata: map[sensors.Metadata]string{sensors.ParticleSize: "1um"},
+	})
+	measurements = append(measurements, sensors.MeasurementRecording{
+		Measure:  &sensors.ParticleCount,
+		Value:    float64(frame.Particles2_5um),
+		Sensor:   sensor,
+		Metadata: map[sensors.Metadata]string{sensors.ParticleSize: "2.5um"},
+	})
+	measurements = append(measurements, sensors.MeasurementRecording{
+		Measure:  &sensors.ParticleCount,
+		Value:    float64(frame.Particles5um),
+		Sensor:   sensor,
+		Metadata: map[sensors.Metadata]string{sensors.ParticleSize: "5.0um"},
+	})
+	measurements = append(measurements, sensors.MeasurementRecording{
+		Measure:  &sensors.ParticleCount,
+		Value:    float64(frame.Particles10um),
+		Sensor:   sensor,
+		Metadata: map[sensors.Metadata]string{sensors.ParticleSize: "10um"},
+	})
+	return measurements
+}
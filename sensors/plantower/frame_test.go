@@ -0,0 +1,162 @@
+package plantower
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Sample frames in the layout sent by a PMSA003I and a PMS5003, and one with an error code.
+const (
+	capturedPMSA003I = "424d001c0005000700080005000700080366010c00330007000200029700021e"
+	capturedPMS5003  = "424d001c000c00120015000c0012001507dd025c0071000c0003000180000354"
+	capturedError    = "424d001c000300040004000300040004025e00b400180002000000009704028a"
+)
+
+var capturedPMSA003IFrame = Frame{PM1Standard: 5, PM2_5Standard: 7, PM10Standard: 8, PM1Env: 5, PM2_5Env: 7, PM10Env: 8,
+	Particles0_3um: 870, Particles0_5um: 268, Particles1um: 51, Particles2_5um: 7, Particles5um: 2,
+	Particles10um: 2, Version: 0x97}
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("invalid test frame %q: %v", s, err)
+	}
+	return data
+}
+
+func TestDecodeFrame(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		frame Frame
+		err   string
+	}{
+		{
+			name:  "pmsa003i",
+			data:  capturedPMSA003I,
+			frame: capturedPMSA003IFrame,
+		},
+		{
+			name: "pms5003",
+			data: capturedPMS5003,
+			frame: Frame{PM1Standard: 12, PM2_5Standard: 18, PM10Standard: 21, PM1Env: 12, PM2_5Env: 18, PM10Env: 21,
+				Particles0_3um: 2013, Particles0_5um: 604, Particles1um: 113, Particles2_5um: 12, Particles5um: 3,
+				Particles10um: 1, Version: 0x80},
+		},
+		{
+			name: "error code",
+			data: capturedError,
+			frame: Frame{PM1Standard: 3, PM2_5Standard: 4, PM10Standard: 4, PM1Env: 3, PM2_5Env: 4, PM10Env: 4,
+				Particles0_3um: 606, Particles0_5um: 180, Particles1um: 24, Particles2_5um: 2, Version: 0x97, ErrorCode: 0x04},
+		},
+		{
+			name:  "leading garbage",
+			data:  "00ff1c02" + capturedPMSA003I,
+			frame: capturedPMSA003IFrame,
+		},
+		{
+			name:  "tail of previous frame",
+			data:  capturedPMS5003[40:] + capturedPMSA003I,
+			frame: capturedPMSA003IFrame,
+		},
+		{
+			name:  "false start bytes",
+			data:  "424d0000" + capturedPMSA003I,
+			frame: capturedPMSA003IFrame,
+		},
+		{
+			name: "bad checksum",
+			data: capturedPMSA003I[:62] + "1f",
+			err:  "checksum",
+		},
+		{
+			name: "corrupted value",
+			data: capturedPMSA003I[:8] + "0105" + capturedPMSA003I[12:],
+			err:  "checksum",
+		},
+		{
+			name: "bad length",
+			data: "424d001e" + capturedPMSA003I[8:],
+			err:  "length",
+		},
+		{
+			name: "truncated",
+			data: capturedPMSA003I[:40],
+			err:  "truncated",
+		},
+		{
+			name: "no start bytes",
+			data: strings.Repeat("00", frameSize),
+			err:  "no frame start",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			frame, err := decodeFrame(mustDecodeHex(t, test.data))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("decodeFrame() error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeFrame() failed: %v", err)
+			}
+			if frame != test.frame {
+				t.Errorf("decodeFrame() = %+v, want %+v", frame, test.frame)
+			}
+		})
+	}
+}
+
+// fakeTransport returns the frames in order, then fails.
+type fakeTransport struct {
+	frames []string
+}
+
+func (f *fakeTransport) readFrame() ([]byte, error) {
+	if len(f.frames) == 0 {
+		return nil, errors.New("no more frames")
+	}
+	data, err := hex.DecodeString(f.frames[0])
+	f.frames = f.frames[1:]
+	return data, err
+}
+
+func TestFrameReader(t *testing.T) {
+	badChecksum := capturedPMSA003I[:62] + "1f"
+	tests := []struct {
+		name      string
+		frames    []string
+		pm2_5     uint16
+		badFrames uint64
+		errorCode uint8
+		fail      bool
+	}{
+		{name: "valid", frames: []string{capturedPMSA003I}, pm2_5: 7},
+		{name: "retry after bad checksum", frames: []string{badChecksum, capturedPMS5003}, pm2_5: 18, badFrames: 1},
+		{name: "error code", frames: []string{capturedError}, pm2_5: 4, errorCode: 0x04},
+		{name: "retries exhausted", frames: []string{badChecksum, badChecksum, badChecksum, capturedPMSA003I}, badFrames: 3, fail: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader := frameReader{transport: &fakeTransport{frames: test.frames}}
+			frame, err := reader.read("test")
+			if test.fail != (err != nil) {
+				t.Fatalf("read() error = %v, want failure %t", err, test.fail)
+			}
+			if frame.PM2_5Standard != test.pm2_5 {
+				t.Errorf("read() PM2.5 = %d, want %d", frame.PM2_5Standard, test.pm2_5)
+			}
+			if reader.badFrames != test.badFrames {
+				t.Errorf("bad frames = %d, want %d", reader.badFrames, test.badFrames)
+			}
+			if reader.errorCode != test.errorCode {
+				t.Errorf("error code = 0x%02x, want 0x%02x", reader.errorCode, test.errorCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package plantower
+
+import (
+	"fmt"
+	"strings"
+
+	"azuremyst.org/go-home-sensors/log"
+	"azuremyst.org/go-home-sensors/sensors"
+	"azuremyst.org/go-home-sensors/serial"
+	"periph.io/x/conn/v3/i2c"
+)
+
+const (
+	PMS_MODE_ACTIVE  = "active"
+	PMS_MODE_PASSIVE = "passive"
+)
+
+// Commands of the UART protocol, each followed by a data word.
+const (
+	pmsCommandRead  = 0xE2 // Request a frame in passive mode
+	pmsCommandMode  = 0xE1 // 0 passive, 1 active
+	pmsCommandSleep = 0xE4 // 0 sleep, 1 wake up
+)
+
+// PMSSerialConfig holds the options of the [sensors.pms5003] and [sensors.pms7003] sections, the
+// port is configured in their serial section. In active mode the sensor sends a frame at least
+// every 2.3s, the read timeout has to be longer, as the default one is.
+type PMSSerialConfig struct {
+	// active (default) sends frames continuously, passive only on request.
+	Mode string
+}
+
+// uartTransport reads the frames of a sensor connected to a serial port.
+type uartTransport struct {
+	port    *serial.Port
+	passive bool
+}
+
+func (t *uartTransport) command(command byte, data uint16) error {
+	frame := []byte{0x42, 0x4D, command, byte(data >> 8), byte(data)}
+	checksum := sumUINT8(frame)
+	_, err := t.port.Write(append(frame, byte(checksum>>8), byte(checksum)))
+	return err
+}
+
+// readFrame returns the next frame starting with the 0x42 0x4D start bytes, decodeFrame
+// validates the rest.
+func (t *uartTransport) readFrame() ([]byte, error) {
+	// Drops stale frames in active mode, and in passive mode the reply to the mode command, which
+	// would otherwise be read as the requested frame.
+	if err := t.port.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to flush serial port: %v", err)
+	}
+	if t.passive {
+		if err := t.command(pmsCommandRead, 0); err != nil {
+			return nil, fmt.Errorf("failed to request frame: %v", err)
+		}
+	}
+
+	frame := make([]byte, frameSize)
+	for matched := 0; matched < 2; {
+		if _, err := t.port.Read(frame[matched : matched+1]); err != nil {
+			return nil, fmt.Errorf("error while reading from serial port: %v", err)
+		}
+		switch {
+		case frame[matched] == frameStart[matched]:
+			matched++
+		case frame[matched] == frameStart[0]:
+			matched = 1
+		default:
+			matched = 0
+		}
+	}
+	if err := t.port.ReadFull(frame[2:]); err != nil {
+		return nil, fmt.Errorf("error while reading from serial port: %v", err)
+	}
+	return frame, nil
+}
+
+// pmsSerial is the UART protocol shared by the PMS5003 and PMS7003.
+type pmsSerial struct {
+	config    PMSSerialConfig
+	transport *uartTransport
+	reader    frameReader
+	Frame
+}
+
+func (pms *pmsSerial) Configure(decode func(v interface{}) error) error {
+	if err := decode(&pms.config); err != nil {
+		return err
+	}
+	switch pms.config.Mode {
+	case "":
+		pms.config.Mode = PMS_MODE_ACTIVE
+	case PMS_MODE_ACTIVE, PMS_MODE_PASSIVE:
+	default:
+		return fmt.Errorf("unknown mode %q", pms.config.Mode)
+	}
+	return nil
+}
+
+func (pms *pmsSerial) initialize(name string, port *serial.Port) {
+	pms.transport = &uartTransport{port: port, passive: pms.config.Mode == PMS_MODE_PASSIVE}
+	pms.reader.transport = pms.transport
+
+	var mode uint16
+	if !pms.transport.passive {
+		mode = 1
+	}
+	if err := pms.transport.command(pmsCommandMode, mode); err != nil {
+		log.ErrorLog.Printf("Failed to set %s mode: %q", pms.config.Mode, err)
+	}
+	log.InfoLog.Printf("Plantower %s\n\tPort: %s\n\tMode: %s", name, port, pms.config.Mode)
+}
+
+func (pms *pmsSerial) collect(name string) []sensors.MeasurementRecording {
+	if pms.transport == nil {
+		return nil
+	}
+	frame, err := pms.reader.read(name)
+	if err != nil {
+		log.ErrorLog.Printf("Failed to read frame: %v", err)
+		return pms.reader.recordings(name)
+	}
+	pms.Frame = frame
+	return append(pms.Frame.recordings(name), pms.reader.recordings(name)...)
+}
+
+// Sleep stops the fan and laser, extending their life between sparse readings.
+func (pms *pmsSerial) Sleep() error {
+	return pms.transport.command(pmsCommandSleep, 0)
+}
+
+// WakeUp restarts the fan, the readings are stable after 30 seconds.
+func (pms *pmsSerial) WakeUp() error {
+	return pms.transport.command(pmsCommandSleep, 1)
+}
+
+func (pms *pmsSerial) Operations() map[string]sensors.Operation {
+	return map[string]sensors.Operation{
+		"sleep": {
+			Description: "Stop the fan and laser until woken up",
+			Run: func(args map[string]string) (string, error) {
+				return "Sleeping", pms.Sleep()
+			},
+		},
+		"wakeup": {
+			Description: "Restart the fan and laser, the readings are stable after 30 seconds",
+			Run: func(args map[string]string) (string, error) {
+				return "Woken up", pms.WakeUp()
+			},
+		},
+	}
+}
+
+func (pms *pmsSerial) Close() error {
+	if pms.transport == nil {
+		return nil
+	}
+	return pms.transport.port.Close()
+}
+
+type PMS5003 struct {
+	pmsSerial
+}
+
+type PMS7003 struct {
+	pmsSerial
+}
+
+func init() {
+	sensors.RegisterSensor(&PMS5003{})
+	sensors.RegisterSensor(&PMS7003{})
+}
+
+// Initialize is not used, the sensor is connected to a serial port.
+func (pms *PMS5003) Initialize(bus i2c.Bus, addr uint16) {
+}
+
+func (pms *PMS5003) InitializeSerial(port *serial.Port) {
+	pms.initialize(pms.Name(), port)
+}
+
+func (pms *PMS5003) Name() string {
+	return "pms5003"
+}
+
+func (pms *PMS5003) Family(name string) bool {
+	return strings.EqualFold(pms.Name(), name)
+}
+
+func (pms *PMS5003) Collect() []sensors.MeasurementRecording {
+	return pms.collect(pms.Name())
+}
+
+// Initialize is not used, the sensor is connected to a serial port.
+func (pms *PMS7003) Initialize(bus i2c.Bus, addr uint16) {
+}
+
+func (pms *PMS7003) InitializeSerial(port *serial.Port) {
+	pms.initialize(pms.Name(), port)
+}
+
+func (pms *PMS7003) Name() string {
+	return "pms7003"
+}
+
+func (pms *PMS7003) Family(name string) bool {
+	return strings.EqualFold(pms.Name(), name)
+}
+
+func (pms *PMS7003) Collect() []sensors.MeasurementRecording {
+	return pms.collect(pms.Name())
+}
@@ -0,0 +1,284 @@
+package senseair
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"azuremyst.org/go-home-sensors/log"
+	"azuremyst.org/go-home-sensors/sensors"
+	"azuremyst.org/go-home-sensors/serial"
+	"periph.io/x/conn/v3/i2c"
+)
+
+// Modbus address every S8 answers to.
+const s8Address = 0xFE
+
+const (
+	modbusReadHolding  = 0x03
+	modbusReadInput    = 0x04
+	modbusWriteHolding = 0x06
+)
+
+// Registers, addresses start at 0 while the datasheet numbers them from 1 (IR1, HR1, ...).
+const (
+	S8_IR_METER_STATUS   = 0x00
+	S8_IR_CO2            = 0x03
+	S8_HR_ACKNOWLEDGE    = 0x00
+	S8_HR_COMMAND        = 0x01
+	S8_HR_ABC_PERIOD     = 0x1F // Hours, 0 disables ABC
+	S8_IR_FIRMWARE       = 0x1C
+	S8_IR_SENSOR_ID_HIGH = 0x1D
+)
+
+const (
+	s8BackgroundCalibration = 0x7C06 // Sets the current concentration as 400ppm
+	s8BackgroundAck         = 1 << 5
+)
+
+// S8Config holds the options of the [sensors.s8] section, the port is configured in its serial
+// section. Unset options keep the value stored on the device.
+type S8Config struct {
+	// Hours between the automatic baseline corrections, 0 disables them. The default is 180.
+	ABCPeriod *uint16 `toml:"abc_period"`
+}
+
+type S8 struct {
+	port *serial.Port
+	mu   sync.Mutex
+
+	config   S8Config
+	sensorID uint32
+	co2      float64
+}
+
+func init() {
+	sensors.RegisterSensor(&S8{})
+}
+
+func (s8 *S8) Name() string {
+	return "s8"
+}
+
+func (s8 *S8) Family(name string) bool {
+	return strings.EqualFold(name, s8.Name()) || strings.EqualFold(name, "senseair_s8")
+}
+
+func (s8 *S8) Configure(decode func(v interface{}) error) error {
+	return decode(&s8.config)
+}
+
+// Initialize is not used, the sensor is connected to a serial port.
+func (s8 *S8) Initialize(bus i2c.Bus, addr uint16) {
+}
+
+func (s8 *S8) InitializeSerial(port *serial.Port) {
+	s8.port = port
+
+	if ids, err := s8.readRegisters(modbusReadInput, S8_IR_SENSOR_ID_HIGH, 2); err != nil {
+		log.ErrorLog.Printf("Failed to read sensor ID: %q", err)
+	} else {
+		s8.sensorID = uint32(ids[0])<<16 | uint32(ids[1])
+	}
+	if s8.config.ABCPeriod != nil {
+		if err := s8.SetABCPeriod(*s8.config.ABCPeriod); err != nil {
+			log.ErrorLog.Printf("Failed to set ABC period: %q", err)
+		}
+	}
+
+	abc := "unknown"
+	if period, err := s8.readRegisters(modbusReadHolding, S8_HR_ABC_PERIOD, 1); err == nil {
+		abc = fmt.Sprintf("%dh", period[0])
+	}
+	log.InfoLog.Printf("Senseair S8\n\tPort: %s\n\tSensorID: %08X\n\tABCPeriod: %s", port, s8.sensorID, abc)
+}
+
+func (s8 *S8) Collect() []sensors.MeasurementRecording {
+	if s8.port == nil {
+		return nil
+	}
+	status, err := s8.readRegisters(modbusReadInput, S8_IR_METER_STATUS, 1)
+	if err != nil {
+		log.ErrorLog.Printf("Failed to read meter status: %q", err)
+		return nil
+	}
+	if status[0] != 0 {
+		log.ErrorLog.Printf("S8 meter status 0x%04x, skipping the reading", status[0])
+		return nil
+	}
+	co2, err := s8.readRegisters(modbusReadInput, S8_IR_CO2, 1)
+	if err != nil {
+		log.ErrorLog.Printf("Failed to read CO2: %q", err)
+		return nil
+	}
+	s8.co2 = float64(co2[0])
+
+	return []sensors.MeasurementRecording{{
+		Measure: &sensors.CarbonDioxide,
+		Value:   s8.co2,
+		Sensor:  s8.Name(),
+	}}
+}
+
+func (s8 *S8) Operations() map[string]sensors.Operation {
+	return map[string]sensors.Operation{
+		"zero": {
+			Description: "Background calibration to 400ppm, the sensor has to be in fresh air for a few minutes first",
+			Run: func(args map[string]string) (string, error) {
+				return "Calibrated to 400ppm", s8.BackgroundCalibration()
+			},
+		},
+		"abc": {
+			Description: "Hours between the automatic baseline corrections, 0 disables them",
+			Args:        []string{"hours"},
+			Run: func(args map[string]string) (string, error) {
+				hours, err := strconv.ParseUint(args["hours"], 10, 16)
+				if err != nil {
+					return "", fmt.Errorf("invalid hours %q: %v", args["hours"], err)
+				}
+				return fmt.Sprintf("ABC period: %dh", hours), s8.SetABCPeriod(uint16(hours))
+			},
+		},
+	}
+}
+
+func (s8 *S8) SetABCPeriod(hours uint16) error {
+	return s8.writeRegister(S8_HR_ABC_PERIOD, hours)
+}
+
+// BackgroundCalibration sets the current concentration as the 400ppm baseline and waits for the
+// sensor to acknowledge it.
+func (s8 *S8) BackgroundCalibration() error {
+	if err := s8.writeRegister(S8_HR_ACKNOWLEDGE, 0); err != nil {
+		return err
+	}
+	if err := s8.writeRegister(S8_HR_COMMAND, s8BackgroundCalibration); err != nil {
+		return err
+	}
+	time.Sleep(4 * time.Second)
+	ack, err := s8.readRegisters(modbusReadHolding, S8_HR_ACKNOWLEDGE, 1)
+	if err != nil {
+		return err
+	}
+	if !calibrationAcknowledged(ack[0]) {
+		return fmt.Errorf("calibration not acknowledged")
+	}
+	return nil
+}
+
+// calibrationAcknowledged checks the flag the S8 sets in the acknowledgement register once the
+// background calibration is done.
+func calibrationAcknowledged(ack uint16) bool {
+	return ack&s8BackgroundAck != 0
+}
+
+func (s8 *S8) Close() error {
+	if s8.port == nil {
+		return nil
+	}
+	return s8.port.Close()
+}
+
+func (s8 *S8) readRegisters(function byte, address uint16, count uint16) ([]uint16, error) {
+	request := make([]byte, 6)
+	request[0] = s8Address
+	request[1] = function
+	binary.BigEndian.PutUint16(request[2:4], address)
+	binary.BigEndian.PutUint16(request[4:6], count)
+
+	response, err := s8.transaction(request, 3+2*int(count)+2)
+	if err != nil {
+		return nil, err
+	}
+	return registerValues(response, count)
+}
+
+// registerValues decodes the values of a read registers response.
+func registerValues(response []byte, count uint16) ([]uint16, error) {
+	if int(response[2]) != 2*int(count) {
+		return nil, fmt.Errorf("unexpected byte count %d", response[2])
+	}
+	values := make([]uint16, count)
+	for i := range values {
+		values[i] = binary.BigEndian.Uint16(response[3+2*i : 5+2*i])
+	}
+	return values, nil
+}
+
+func (s8 *S8) writeRegister(address uint16, value uint16) error {
+	request := make([]byte, 6)
+	request[0] = s8Address
+	request[1] = modbusWriteHolding
+	binary.BigEndian.PutUint16(request[2:4], address)
+	binary.BigEndian.PutUint16(request[4:6], value)
+
+	// The response echoes the request.
+	_, err := s8.transaction(request, 8)
+	return err
+}
+
+// transaction sends the Modbus RTU request and reads the response of the given size.
+func (s8 *S8) transaction(request []byte, size int) ([]byte, error) {
+	s8.mu.Lock()
+	defer s8.mu.Unlock()
+
+	crc := modbusCRC(request)
+	request = append(request, byte(crc), byte(crc>>8))
+	if err := s8.port.Flush(); err != nil {
+		return nil, err
+	}
+	if _, err := s8.port.Write(request); err != nil {
+		return nil, fmt.Errorf("error while sending request: %v", err)
+	}
+
+	response := make([]byte, 3, size)
+	if err := s8.port.ReadFull(response); err != nil {
+		return nil, fmt.Errorf("error while reading response: %v", err)
+	}
+	if response[1]&0x80 != 0 {
+		// Exception responses are 5 bytes, the third one is the exception code.
+		size = 5
+	}
+	response = response[:size]
+	if err := s8.port.ReadFull(response[3:]); err != nil {
+		return nil, fmt.Errorf("error while reading response: %v", err)
+	}
+	if err := checkResponse(request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// checkResponse validates the CRC of the response and that it answers the request.
+func checkResponse(request []byte, response []byte) error {
+	size := len(response)
+	if crc := modbusCRC(response[:size-2]); byte(crc) != response[size-2] || byte(crc>>8) != response[size-1] {
+		return fmt.Errorf("invalid CRC in response % x", response)
+	}
+	if response[0] != request[0] || response[1]&0x7F != request[1] {
+		return fmt.Errorf("unexpected response % x", response)
+	}
+	if response[1]&0x80 != 0 {
+		return fmt.Errorf("modbus exception 0x%02x for function 0x%02x", response[2], request[1])
+	}
+	return nil
+}
+
+// modbusCRC is the CRC-16/MODBUS, sent low byte first.
+func modbusCRC(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = (crc >> 1) ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,96 @@
+package senseair
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("invalid test frame %q: %v", s, err)
+	}
+	return data
+}
+
+func TestModbusCRC(t *testing.T) {
+	tests := []struct {
+		data string
+		want uint16
+	}{
+		// CRC-16/MODBUS check value of "123456789".
+		{hex.EncodeToString([]byte("123456789")), 0x4B37},
+		// Requests of the Senseair S8 Modbus documentation, the CRC is sent low byte first.
+		{"fe0400030001", 0xC5D5},
+		{"fe0300000001", 0x0590},
+		{"fe0600000000", 0xC59D},
+		{"fe0600017c06", 0xC76C},
+	}
+	for _, tt := range tests {
+		if crc := modbusCRC(mustDecodeHex(t, tt.data)); crc != tt.want {
+			t.Errorf("modbusCRC(%s) = 0x%04x, want 0x%04x", tt.data, crc, tt.want)
+		}
+	}
+}
+
+// withCRC appends the CRC of the frame low byte first.
+func withCRC(t *testing.T, s string) []byte {
+	t.Helper()
+	frame := mustDecodeHex(t, s)
+	crc := modbusCRC(frame)
+	return append(frame, byte(crc), byte(crc>>8))
+}
+
+func TestCheckResponse(t *testing.T) {
+	readCO2 := withCRC(t, "fe0400030001")
+	tests := []struct {
+		name     string
+		request  []byte
+		response []byte
+		err      string
+	}{
+		{"read co2", readCO2, withCRC(t, "fe04020190"), ""},
+		{"bad crc", readCO2, mustDecodeHex(t, "fe04020190ffff"), "invalid CRC"},
+		{"other address", readCO2, withCRC(t, "6804020190"), "unexpected response"},
+		{"other function", readCO2, withCRC(t, "fe03020190"), "unexpected response"},
+		{"exception", readCO2, withCRC(t, "fe8402"), "modbus exception 0x02"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkResponse(tt.request, tt.response)
+			if tt.err == "" && err != nil {
+				t.Errorf("checkResponse(% x) = %v", tt.response, err)
+			} else if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("checkResponse(% x) = %v, want %q", tt.response, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCalibrationAcknowledged(t *testing.T) {
+	tests := []struct {
+		response string
+		ack      bool
+	}{
+		{"fe03020020", true},
+		{"fe03020000", false},
+		// Other flags don't acknowledge the background calibration.
+		{"fe030200df", false},
+	}
+	request := withCRC(t, "fe0300000001")
+	for _, tt := range tests {
+		response := withCRC(t, tt.response)
+		if err := checkResponse(request, response); err != nil {
+			t.Fatalf("checkResponse(% x) = %v", response, err)
+		}
+		ack, err := registerValues(response, 1)
+		if err != nil {
+			t.Fatalf("registerValues(% x) = %v", response, err)
+		}
+		if calibrationAcknowledged(ack[0]) != tt.ack {
+			t.Errorf("acknowledged(%s) = %t, want %t", tt.response, !tt.ack, tt.ack)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package sensirion
+
+import "math"
+
+// Port of the Sensirion gas index algorithm (v3), turning the raw SGP4x signals into the VOC and
+// NOx indices the SEN5x computes on the device. The method names follow the C implementation.
+
+const (
+	gasIndexVOC = iota
+	gasIndexNOx
+)
+
+const (
+	gasIndexInitialBlackout           = 45.0
+	gasIndexIndexGain                 = 230.0
+	gasIndexSrawStdInitial            = 50.0
+	gasIndexSrawStdBonusVOC           = 220.0
+	gasIndexSrawStdNOx                = 2000.0
+	gasIndexTauMeanHours              = 12.0
+	gasIndexTauVarianceHours          = 12.0
+	gasIndexTauInitialMeanVOC         = 20.0
+	gasIndexTauInitialMeanNOx         = 1200.0
+	gasIndexInitDurationMeanVOC       = 3600.0 * 0.75
+	gasIndexInitDurationMeanNOx       = 3600.0 * 4.75
+	gasIndexInitTransitionMean        = 0.01
+	gasIndexTauInitialVariance        = 2500.0
+	gasIndexInitDurationVarianceVOC   = 3600.0 * 1.45
+	gasIndexInitDurationVarianceNOx   = 3600.0 * 5.70
+	gasIndexInitTransitionVariance    = 0.01
+	gasIndexGatingThresholdVOC        = 340.0
+	gasIndexGatingThresholdNOx        = 30.0
+	gasIndexGatingThresholdInitial    = 510.0
+	gasIndexGatingThresholdTransition = 0.09
+	gasIndexGatingVOCMaxMinutes       = 60.0 * 3
+	gasIndexGatingNOxMaxMinutes       = 60.0 * 12
+	gasIndexGatingMaxRatio            = 0.3
+	gasIndexSigmoidL                  = 500.0
+	gasIndexSigmoidKVOC               = -0.0065
+	gasIndexSigmoidX0VOC              = 213.0
+	gasIndexSigmoidKNOx               = -0.0101
+	gasIndexSigmoidX0NOx              = 614.0
+	gasIndexVOCIndexOffset            = 100.0
+	gasIndexNOxIndexOffset            = 1.0
+	gasIndexLPTauFast                 = 20.0
+	gasIndexLPTauSlow                 = 500.0
+	gasIndexLPAlpha                   = -0.2
+	gasIndexVOCSrawMinimum            = 20000
+	gasIndexNOxSrawMinimum            = 10000
+	gasIndexPersistenceUptimeGamma    = 3.0 * 3600
+	gasIndexGammaScaling              = 64.0
+	gasIndexAdditionalGammaMeanScale  = 8.0
+	gasIndexFix16Max                  = 32767.0
+)
+
+type gasIndexAlgorithm struct {
+	algorithmType            int
+	samplingInterval         float64 // Seconds
+	indexOffset              float64
+	srawMinimum              int32
+	gatingMaxDurationMinutes float64
+	initDurationMean         float64
+	initDurationVariance     float64
+	gatingThreshold          float64
+	indexGain                float64
+	tauMeanHours             float64
+	tauVarianceHours         float64
+	srawStdInitial           float64
+
+	uptime   float64
+	sraw     float64
+	gasIndex float64
+
+	estimator gasIndexEstimator
+	mox       struct{ srawStd, srawMean float64 }
+	sigmoid   struct{ k, x0, offsetDefault float64 }
+	lowpass   struct {
+		a1, a2      float64
+		initialized bool
+		x1, x2, x3  float64
+	}
+}
+
+// gasIndexEstimator tracks the mean and standard deviation of the raw signal, the baseline the
+// index is relative to.
+type gasIndexEstimator struct {
+	initialized           bool
+	mean                  float64
+	srawOffset            float64
+	std                   float64
+	gammaMean             float64
+	gammaVariance         float64
+	gammaInitialMean      float64
+	gammaInitialVariance  float64
+	currentGammaMean      float64
+	currentGammaVariance  float64
+	uptimeGamma           float64
+	uptimeGating          float64
+	gatingDurationMinutes float64
+	sigmoidK, sigmoidX0   float64
+}
+
+func newGasIndexAlgorithm(algorithmType int, samplingInterval float64) *gasIndexAlgorithm {
+	algorithm := &gasIndexAlgorithm{algorithmType: algorithmType, samplingInterval: samplingInterval}
+	if algorithmType == gasIndexNOx {
+		algorithm.indexOffset = gasIndexNOxIndexOffset
+		algorithm.srawMinimum = gasIndexNOxSrawMinimum
+		algorithm.gatingMaxDurationMinutes = gasIndexGatingNOxMaxMinutes
+		algorithm.initDurationMean = gasIndexInitDurationMeanNOx
+		algorithm.initDurationVariance = gasIndexInitDurationVarianceNOx
+		algorithm.gatingThreshold = gasIndexGatingThresholdNOx
+	} else {
+		algorithm.indexOffset = gasIndexVOCIndexOffset
+		algorithm.srawMinimum = gasIndexVOCSrawMinimum
+		algorithm.gatingMaxDurationMinutes = gasIndexGatingVOCMaxMinutes
+		algorithm.initDurationMean = gasIndexInitDurationMeanVOC
+		algorithm.initDurationVariance = gasIndexInitDurationVarianceVOC
+		algorithm.gatingThreshold = gasIndexGatingThresholdVOC
+	}
+	algorithm.indexGain = gasIndexIndexGain
+	algorithm.tauMeanHours = gasIndexTauMeanHours
+	algorithm.tauVarianceHours = gasIndexTauVarianceHours
+	algorithm.srawStdInitial = gasIndexSrawStdInitial
+	algorithm.reset()
+	return algorithm
+}
+
+func (g *gasIndexAlgorithm) reset() {
+	g.uptime = 0
+	g.sraw = 0
+	g.gasIndex = 0
+	g.initInstances()
+}
+
+func (g *gasIndexAlgorithm) initInstances() {
+	g.estimatorSetParameters()
+	g.mox.srawStd, g.mox.srawMean = g.estimator.std, g.estimatorMean()
+	if g.algorithmType == gasIndexNOx {
+		g.sigmoid.x0, g.sigmoid.k, g.sigmoid.offsetDefault = gasIndexSigmoidX0NOx, gasIndexSigmoidKNOx, gasIndexNOxIndexOffset
+	} else {
+		g.sigmoid.x0, g.sigmoid.k, g.sigmoid.offsetDefault = gasIndexSigmoidX0VOC, gasIndexSigmoidKVOC, gasIndexVOCIndexOffset
+	}
+	g.lowpass.a1 = g.samplingInterval / (gasIndexLPTauFast + g.samplingInterval)
+	g.lowpass.a2 = g.samplingInterval / (gasIndexLPTauSlow + g.samplingInterval)
+	g.lowpass.initialized = false
+}
+
+// setTuning applies the tuning parameters and restarts the learning, like the SEN5x does.
+// setTuning applies the tuning parameters, the ones left at 0, e.g. missing from a partial config
+// table, keep their default.
+func (g *gasIndexAlgorithm) setTuning(tuning SEN5XTuning) {
+	set := func(parameter *float64, value int16) {
+		if value != 0 {
+			*parameter = float64(value)
+		}
+	}
+	set(&g.indexOffset, tuning.IndexOffset)
+	set(&g.tauMeanHours, tuning.LearningTimeOffsetHours)
+	set(&g.tauVarianceHours, tuning.LearningTimeGainHours)
+	set(&g.gatingMaxDurationMinutes, tuning.GatingMaxDurationMinutes)
+	set(&g.srawStdInitial, tuning.StdInitial)
+	set(&g.indexGain, tuning.GainFactor)
+	g.initInstances()
+}
+
+// process feeds a raw signal sampled every samplingInterval and returns the index, 0 during the
+// initial blackout.
+func (g *gasIndexAlgorithm) process(sraw int32) int32 {
+	if g.uptime <= gasIndexInitialBlackout {
+		g.uptime += g.samplingInterval
+	} else {
+		if sraw > 0 && sraw < 65000 {
+			if sraw < g.srawMinimum+1 {
+				sraw = g.srawMinimum + 1
+			} else if sraw > g.srawMinimum+32767 {
+				sraw = g.srawMinimum + 32767
+			}
+			g.sraw = float64(sraw - g.srawMinimum)
+		}
+		if g.algorithmType == gasIndexVOC || g.estimator.initialized {
+			g.gasIndex = g.sigmoidScaled(g.moxModel(g.sraw))
+		} else {
+			g.gasIndex = g.indexOffset
+		}
+		g.gasIndex = g.adaptiveLowpass(g.gasIndex)
+		if g.gasIndex < 0.5 {
+			g.gasIndex = 0.5
+		}
+		if g.sraw > 0 {
+			g.estimatorProcess(g.sraw)
+			g.mox.srawStd, g.mox.srawMean = g.estimator.std, g.estimatorMean()
+		}
+	}
+	return int32(g.gasIndex + 0.5)
+}
+
+func (g *gasIndexAlgorithm) estimatorSetParameters() {
+	e := &g.estimator
+	e.initialized = false
+	e.mean = 0
+	e.srawOffset = 0
+	e.std = g.srawStdInitial
+	e.gammaMean = gasIndexAdditionalGammaMeanScale * gasIndexGammaScaling * (g.samplingInterval / 3600) /
+		(g.tauMeanHours + g.samplingInterval/3600)
+	e.gammaVariance = gasIndexGammaScaling * (g.samplingInterval / 3600) / (g.tauVarianceHours + g.samplingInterval/3600)
+	tauInitialMean := gasIndexTauInitialMeanVOC
+	if g.algorithmType == gasIndexNOx {
+		tauInitialMean = gasIndexTauInitialMeanNOx
+	}
+	e.gammaInitialMean = gasIndexAdditionalGammaMeanScale * gasIndexGammaScaling * g.samplingInterval /
+		(tauInitialMean + g.samplingInterval)
+	e.gammaInitialVariance = gasIndexGammaScaling * g.samplingInterval / (gasIndexTauInitialVariance + g.samplingInterval)
+	e.currentGammaMean = 0
+	e.currentGammaVariance = 0
+	e.uptimeGamma = 0
+	e.uptimeGating = 0
+	e.gatingDurationMinutes = 0
+}
+
+func (g *gasIndexAlgorithm) estimatorMean() float64 {
+	return g.estimator.mean + g.estimator.srawOffset
+}
+
+func (g *gasIndexAlgorithm) estimatorSigmoid(sample float64) float64 {
+	x := g.estimator.sigmoidK * (sample - g.estimator.sigmoidX0)
+	if x < -50 {
+		return 1
+	} else if x > 50 {
+		return 0
+	}
+	return 1 / (1 + math.Exp(x))
+}
+
+func (g *gasIndexAlgorithm) estimatorCalculateGamma() {
+	e := &g.estimator
+	uptimeLimit := gasIndexFix16Max - g.samplingInterval
+	if e.uptimeGamma < uptimeLimit {
+		e.uptimeGamma += g.samplingInterval
+	}
+	if e.uptimeGating < uptimeLimit {
+		e.uptimeGating += g.samplingInterval
+	}
+
+	e.sigmoidX0, e.sigmoidK = g.initDurationMean, gasIndexInitTransitionMean
+	sigmoidGammaMean := g.estimatorSigmoid(e.uptimeGamma)
+	gammaMean := e.gammaMean + (e.gammaInitialMean-e.gammaMean)*sigmoidGammaMean
+	gatingThresholdMean := g.gatingThreshold +
+		(gasIndexGatingThresholdInitial-g.gatingThreshold)*g.estimatorSigmoid(e.uptimeGating)
+	e.sigmoidX0, e.sigmoidK = gatingThresholdMean, gasIndexGatingThresholdTransition
+	sigmoidGatingMean := g.estimatorSigmoid(g.gasIndex)
+	e.currentGammaMean = sigmoidGatingMean * gammaMean
+
+	e.sigmoidX0, e.sigmoidK = g.initDurationVariance, gasIndexInitTransitionVariance
+	sigmoidGammaVariance := g.estimatorSigmoid(e.uptimeGamma)
+	gammaVariance := e.gammaVariance + (e.gammaInitialVariance-e.gammaVariance)*(sigmoidGammaVariance-sigmoidGammaMean)
+	gatingThresholdVariance := g.gatingThreshold +
+		(gasIndexGatingThresholdInitial-g.gatingThreshold)*g.estimatorSigmoid(e.uptimeGating)
+	e.sigmoidX0, e.sigmoidK = gatingThresholdVariance, gasIndexGatingThresholdTransition
+	sigmoidGatingVariance := g.estimatorSigmoid(g.gasIndex)
+	e.currentGammaVariance = sigmoidGatingVariance * gammaVariance
+
+	e.gatingDurationMinutes += (g.samplingInterval / 60) *
+		((1-sigmoidGatingMean)*(1+gasIndexGatingMaxRatio) - gasIndexGatingMaxRatio)
+	if e.gatingDurationMinutes < 0 {
+		e.gatingDurationMinutes = 0
+	}
+	if e.gatingDurationMinutes > g.gatingMaxDurationMinutes {
+		e.uptimeGating = 0
+	}
+}
+
+func (g *gasIndexAlgorithm) estimatorProcess(sraw float64) {
+	e := &g.estimator
+	if !e.initialized {
+		e.initialized = true
+		e.srawOffset = sraw
+		e.mean = 0
+		return
+	}
+
+	if e.mean >= 100 || e.mean <= -100 {
+		e.srawOffset += e.mean
+		e.mean = 0
+	}
+	sraw -= e.srawOffset
+	g.estimatorCalculateGamma()
+	deltaSgp := (sraw - e.mean) / gasIndexGammaScaling
+	c := e.std + math.Abs(deltaSgp)
+	additionalScaling := 1.0
+	if c > 1440 {
+		additionalScaling = (c / 1440) * (c / 1440)
+	}
+	e.std = math.Sqrt(additionalScaling*(gasIndexGammaScaling-e.currentGammaVariance)) *
+		math.Sqrt(e.std*(e.std/(gasIndexGammaScaling*additionalScaling))+
+			e.currentGammaVariance*deltaSgp/additionalScaling*deltaSgp)
+	e.mean += e.currentGammaMean * deltaSgp / gasIndexAdditionalGammaMeanScale
+}
+
+func (g *gasIndexAlgorithm) moxModel(sraw float64) float64 {
+	if g.algorithmType == gasIndexNOx {
+		return (sraw - g.mox.srawMean) / gasIndexSrawStdNOx * g.indexGain
+	}
+	return (sraw - g.mox.srawMean) / -(g.mox.srawStd + gasIndexSrawStdBonusVOC) * g.indexGain
+}
+
+func (g *gasIndexAlgorithm) sigmoidScaled(sample float64) float64 {
+	x := g.sigmoid.k * (sample - g.sigmoid.x0)
+	if x < -50 {
+		return gasIndexSigmoidL
+	} else if x > 50 {
+		return 0
+	}
+	if sample >= 0 {
+		var shift float64
+		if g.sigmoid.offsetDefault == 1 {
+			shift = (500.0 / 499.0) * (1 - g.indexOffset)
+		} else {
+			shift = (gasIndexSigmoidL - 5*g.indexOffset) / 4
+		}
+		return (gasIndexSigmoidL+shift)/(1+math.Exp(x)) - shift
+	}
+	return g.indexOffset / g.sigmoid.offsetDefault * (gasIndexSigmoidL / (1 + math.Exp(x)))
+}
+
+func (g *gasIndexAlgorithm) adaptiveLowpass(sample float64) float64 {
+	lp := &g.lowpass
+	if !lp.initialized {
+		lp.x1, lp.x2, lp.x3 = sample, sample, sample
+		lp.initialized = true
+	}
+	lp.x1 = (1-lp.a1)*lp.x1 + lp.a1*sample
+	lp.x2 = (1-lp.a2)*lp.x2 + lp.a2*sample
+	f1 := math.Exp(gasIndexLPAlpha * math.Abs(lp.x1-lp.x2))
+	tauA := (gasIndexLPTauSlow-gasIndexLPTauFast)*f1 + gasIndexLPTauFast
+	a3 := g.samplingInterval / (g.samplingInterval + tauA)
+	lp.x3 = (1-a3)*lp.x3 + a3*sample
+	return lp.x3
+}
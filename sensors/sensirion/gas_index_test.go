@@ -0,0 +1,105 @@
+package sensirion
+
+import "testing"
+
+// runGasIndex feeds the same raw signal for the given number of seconds and returns the last index.
+func runGasIndex(algorithm *gasIndexAlgorithm, sraw int32, seconds int) int32 {
+	var index int32
+	for i := 0; i < seconds; i++ {
+		index = algorithm.process(sraw)
+	}
+	return index
+}
+
+func TestGasIndexBlackout(t *testing.T) {
+	for _, algorithmType := range []int{gasIndexVOC, gasIndexNOx} {
+		algorithm := newGasIndexAlgorithm(algorithmType, 1)
+		if index := runGasIndex(algorithm, 30000, 45); index != 0 {
+			t.Errorf("type %d: index %d during the blackout, want 0", algorithmType, index)
+		}
+	}
+}
+
+func TestGasIndexSettlesOnOffset(t *testing.T) {
+	tests := []struct {
+		name          string
+		algorithmType int
+		sraw          int32
+		index         int32
+	}{
+		{name: "voc", algorithmType: gasIndexVOC, sraw: 30000, index: 100},
+		{name: "nox", algorithmType: gasIndexNOx, sraw: 15000, index: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			algorithm := newGasIndexAlgorithm(test.algorithmType, 1)
+			if index := runGasIndex(algorithm, test.sraw, 3600); index != test.index {
+				t.Errorf("index %d after an hour of constant signal, want %d", index, test.index)
+			}
+		})
+	}
+}
+
+func TestGasIndexEvents(t *testing.T) {
+	// More VOC lowers the raw signal, more NOx raises it.
+	voc := newGasIndexAlgorithm(gasIndexVOC, 1)
+	runGasIndex(voc, 30000, 3600)
+	if index := runGasIndex(voc, 28000, 300); index <= 150 {
+		t.Errorf("VOC index %d after a VOC event, want above 150", index)
+	}
+
+	nox := newGasIndexAlgorithm(gasIndexNOx, 1)
+	runGasIndex(nox, 15000, 6*3600)
+	if index := runGasIndex(nox, 17000, 300); index <= 5 {
+		t.Errorf("NOx index %d after a NOx event, want above 5", index)
+	}
+}
+
+func TestGasIndexTuning(t *testing.T) {
+	algorithm := newGasIndexAlgorithm(gasIndexVOC, 1)
+	algorithm.setTuning(SEN5XTuning{IndexOffset: 250, LearningTimeOffsetHours: 12, LearningTimeGainHours: 12,
+		GatingMaxDurationMinutes: 180, StdInitial: 50, GainFactor: 230})
+	if index := runGasIndex(algorithm, 30000, 3600); index != 250 {
+		t.Errorf("index %d with an offset of 250, want 250", index)
+	}
+}
+
+// A partial tuning table keeps the defaults of the parameters left out.
+func TestGasIndexPartialTuning(t *testing.T) {
+	tests := []struct {
+		algorithmType int
+		sraw          int32
+		index         int32
+	}{
+		{gasIndexVOC, 30000, 100},
+		{gasIndexNOx, 15000, 1},
+	}
+	for _, test := range tests {
+		algorithm := newGasIndexAlgorithm(test.algorithmType, 1)
+		algorithm.setTuning(SEN5XTuning{LearningTimeOffsetHours: 24})
+		if algorithm.indexGain != gasIndexIndexGain || algorithm.tauMeanHours != 24 {
+			t.Errorf("type %d: gain %v and learning time offset %v, want the default gain and 24", test.algorithmType, algorithm.indexGain, algorithm.tauMeanHours)
+		}
+		if index := runGasIndex(algorithm, test.sraw, 3600); index != test.index {
+			t.Errorf("type %d: index %d after an hour of constant signal, want %d", test.algorithmType, index, test.index)
+		}
+	}
+}
+
+func TestValidateTuning(t *testing.T) {
+	tests := []struct {
+		tuning SEN5XTuning
+		valid  bool
+	}{
+		{SEN5XTuning{}, true},
+		{SEN5XTuning{IndexOffset: 100, LearningTimeOffsetHours: 12, LearningTimeGainHours: 12, GatingMaxDurationMinutes: 180, StdInitial: 50, GainFactor: 230}, true},
+		{SEN5XTuning{IndexOffset: 251}, false},
+		{SEN5XTuning{StdInitial: 5}, false},
+		{SEN5XTuning{GainFactor: -1}, false},
+	}
+	for _, test := range tests {
+		if err := validateTuning(test.tuning); (err == nil) != test.valid {
+			t.Errorf("validateTuning(%+v) = %v, want valid %t", test.tuning, err, test.valid)
+		}
+	}
+}
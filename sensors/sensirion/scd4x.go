@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"math"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -34,8 +35,37 @@ var (
 	SCD4X_GETASCE                          = Command{code: 0x2313, description: "Get asce", delay: time.Duration(1 * time.Millisecond), size: 2}
 	SCD4X_SETASCE                          = Command{code: 0x2416, description: "Set asce", delay: time.Duration(1 * time.Millisecond), size: 0}
 	SCD4X_WAKEUP                           = Command{code: 0x36f6, description: "Wake up", delay: time.Duration(30 * time.Millisecond), size: 0}
+	SCD4X_POWERDOWN                        = Command{code: 0x36E0, description: "Power down", delay: time.Duration(1 * time.Millisecond), size: 0}
+	SCD4X_MEASURESINGLESHOT                = Command{code: 0x219D, description: "Measure single shot", delay: time.Duration(5000 * time.Millisecond), size: 0}
+	SCD4X_MEASURESINGLESHOTRHTONLY         = Command{code: 0x2196, description: "Measure single shot RHT only", delay: time.Duration(50 * time.Millisecond), size: 0}
 )
 
+const (
+	SCD4X_MODE_PERIODIC             = "periodic"
+	SCD4X_MODE_LOW_POWER            = "low_power"
+	SCD4X_MODE_SINGLE_SHOT          = "single_shot"
+	SCD4X_MODE_SINGLE_SHOT_RHT_ONLY = "single_shot_rht_only"
+)
+
+// SCD4XConfig holds the options of the [sensors.scd4x] section. Unset options keep the value
+// stored on the device.
+type SCD4XConfig struct {
+	Altitude          *uint16  // Meters above sea level
+	TemperatureOffset *float64 `toml:"temperature_offset"` // Degrees Celsius
+	ASCEnabled        *bool    `toml:"asc_enabled"`        // Automatic self calibration
+	// periodic, low_power, single_shot or single_shot_rht_only. The single shot modes measure
+	// on demand in Collect and power the sensor down in between. A single_shot measurement
+	// takes 10 seconds, the first one after waking up being discarded, and delays the readings
+	// of the other sensors of the cycle as long. It suits collection frequencies of a minute or more.
+	MeasurementMode string `toml:"measurement_mode"`
+	// Store the settings in the EEPROM, which only supports a limited number of write cycles.
+	// Only done when a setting changed.
+	Persist bool
+	// Sensor whose pressure readings compensate the CO2 measurement each cycle, replacing
+	// the altitude based compensation while its readings are available.
+	PressureSource string `toml:"pressure_source"`
+}
+
 type SCD4XMeasurement struct {
 	Humidity    float64
 	Temperature float64
@@ -50,8 +80,13 @@ type SCD4X struct {
 	device *i2c.Dev
 	mu     sync.Mutex
 
+	config     SCD4XConfig
 	deviceInfo SCD4XDeviceInfo
 	data       SCD4XMeasurement
+
+	altitude            uint16 // Altitude set on the device, used when no pressure reading is available
+	ambientPressure     uint16 // Pressure to apply after waking up in the single shot modes
+	pressureCompensated bool
 }
 
 func init() {
@@ -81,11 +116,156 @@ func (scd4x *SCD4X) Initialize(bus i2c.Bus, addr uint16) {
 
 	log.InfoLog.Printf("Sensirion SCD4x\n\tSerialNumber: %s", scd4x.deviceInfo.serialNumber)
 
-	if err := SCD4X_STARTPERIODICMEASUREMENT.Write(scd4x.device, &scd4x.mu); err != nil {
-		log.ErrorLog.Printf("Failed to start periodic measurement: %q", err)
+	if err := scd4x.applyConfig(); err != nil {
+		log.ErrorLog.Printf("Failed to configure device: %q", err)
+	}
+	if altitude, err := scd4x.GetAltitude(); err != nil {
+		log.ErrorLog.Printf("Failed to read altitude: %q", err)
+	} else {
+		scd4x.altitude = altitude
+	}
+
+	if err := scd4x.startMeasurement(); err != nil {
+		log.ErrorLog.Printf("Failed to start measurement: %q", err)
 	}
 }
 
+func (scd4x *SCD4X) Configure(decode func(v interface{}) error) error {
+	if err := decode(&scd4x.config); err != nil {
+		return err
+	}
+
+	switch scd4x.config.MeasurementMode {
+	case "":
+		scd4x.config.MeasurementMode = SCD4X_MODE_PERIODIC
+	case SCD4X_MODE_PERIODIC, SCD4X_MODE_LOW_POWER, SCD4X_MODE_SINGLE_SHOT, SCD4X_MODE_SINGLE_SHOT_RHT_ONLY:
+	default:
+		return fmt.Errorf("unknown measurement mode %q", scd4x.config.MeasurementMode)
+	}
+	if scd4x.config.TemperatureOffset != nil {
+		if err := validTemperatureOffset(*scd4x.config.TemperatureOffset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyConfig writes the configured settings which differ from the ones on the device and reads
+// them back. The sensor has to be idle, i.e. periodic measurement stopped.
+func (scd4x *SCD4X) applyConfig() error {
+	changed := false
+
+	if scd4x.config.Altitude != nil {
+		altitude, err := scd4x.GetAltitude()
+		if err != nil {
+			return fmt.Errorf("failed to read altitude: %q", err)
+		}
+		if altitude != *scd4x.config.Altitude {
+			if err := scd4x.SetAltitude(*scd4x.config.Altitude); err != nil {
+				return fmt.Errorf("failed to set altitude: %q", err)
+			}
+			if altitude, err = scd4x.GetAltitude(); err != nil {
+				return fmt.Errorf("failed to read back altitude: %q", err)
+			}
+			if altitude != *scd4x.config.Altitude {
+				return fmt.Errorf("altitude is %d after setting it to %d", altitude, *scd4x.config.Altitude)
+			}
+			changed = true
+		}
+	}
+
+	if scd4x.config.TemperatureOffset != nil {
+		offset, err := scd4x.GetTempetatureOffset()
+		if err != nil {
+			return fmt.Errorf("failed to read temperature offset: %q", err)
+		}
+		if math.Abs(offset-*scd4x.config.TemperatureOffset) > 0.01 {
+			if err := scd4x.SetTemperatureOffset(*scd4x.config.TemperatureOffset); err != nil {
+				return fmt.Errorf("failed to set temperature offset: %q", err)
+			}
+			if offset, err = scd4x.GetTempetatureOffset(); err != nil {
+				return fmt.Errorf("failed to read back temperature offset: %q", err)
+			}
+			if math.Abs(offset-*scd4x.config.TemperatureOffset) > 0.01 {
+				return fmt.Errorf("temperature offset is %.2f after setting it to %.2f", offset, *scd4x.config.TemperatureOffset)
+			}
+			changed = true
+		}
+	}
+
+	if scd4x.config.ASCEnabled != nil {
+		enabled, err := scd4x.IsCalibrationEnabled()
+		if err != nil {
+			return fmt.Errorf("failed to read automatic self calibration: %q", err)
+		}
+		if enabled != *scd4x.config.ASCEnabled {
+			if err := scd4x.ToggleCalibration(*scd4x.config.ASCEnabled); err != nil {
+				return fmt.Errorf("failed to toggle automatic self calibration: %q", err)
+			}
+			if enabled, err = scd4x.IsCalibrationEnabled(); err != nil {
+				return fmt.Errorf("failed to read back automatic self calibration: %q", err)
+			}
+			if enabled != *scd4x.config.ASCEnabled {
+				return fmt.Errorf("automatic self calibration is %t after setting it to %t", enabled, *scd4x.config.ASCEnabled)
+			}
+			changed = true
+		}
+	}
+
+	if scd4x.config.Persist && changed {
+		if err := scd4x.PersistSettings(); err != nil {
+			return fmt.Errorf("failed to persist settings: %q", err)
+		}
+	}
+	return nil
+}
+
+func (scd4x *SCD4X) startMeasurement() error {
+	switch scd4x.config.MeasurementMode {
+	case SCD4X_MODE_LOW_POWER:
+		return scd4x.StartLowPeriodicMeasurement()
+	case SCD4X_MODE_SINGLE_SHOT, SCD4X_MODE_SINGLE_SHOT_RHT_ONLY:
+		return scd4x.PowerDown()
+	}
+	return SCD4X_STARTPERIODICMEASUREMENT.Write(scd4x.device, &scd4x.mu)
+}
+
+func (scd4x *SCD4X) singleShot() bool {
+	return scd4x.config.MeasurementMode == SCD4X_MODE_SINGLE_SHOT ||
+		scd4x.config.MeasurementMode == SCD4X_MODE_SINGLE_SHOT_RHT_ONLY
+}
+
+// measureSingleShot wakes the sensor up, measures and powers it down again.
+func (scd4x *SCD4X) measureSingleShot() error {
+	scd4x.WakeUp()
+	defer func() {
+		if err := scd4x.PowerDown(); err != nil {
+			log.ErrorLog.Printf("Failed to power down: %q", err)
+		}
+	}()
+
+	if scd4x.ambientPressure != 0 {
+		if err := scd4x.SetAmbientPressure(scd4x.ambientPressure); err != nil {
+			return err
+		}
+	}
+
+	command := SCD4X_MEASURESINGLESHOT
+	if scd4x.config.MeasurementMode == SCD4X_MODE_SINGLE_SHOT_RHT_ONLY {
+		command = SCD4X_MEASURESINGLESHOTRHTONLY
+	}
+	// The first measurement after waking up is invalid and has to be discarded.
+	for i := 0; i < 2; i++ {
+		if err := command.Write(scd4x.device, &scd4x.mu); err != nil {
+			return err
+		}
+	}
+	if !scd4x.dataReady() {
+		return fmt.Errorf("no data after single shot measurement")
+	}
+	return scd4x.readData()
+}
+
 func (scd4x *SCD4X) Name() string {
 	return "scd4x"
 }
@@ -95,8 +275,14 @@ func (scd4x *SCD4X) Family(name string) bool {
 }
 
 func (scd4x *SCD4X) Collect() []sensors.MeasurementRecording {
-	if scd4x.dataReady() {
-		scd4x.readData()
+	if scd4x.singleShot() {
+		if err := scd4x.measureSingleShot(); err != nil {
+			log.ErrorLog.Printf("Failed to measure: %q", err)
+		}
+	} else if scd4x.dataReady() {
+		if err := scd4x.readData(); err != nil {
+			log.ErrorLog.Printf("Failed to read measurement: %q", err)
+		}
 	}
 
 	measurements := make([]sensors.MeasurementRecording, 0)
@@ -110,14 +296,157 @@ func (scd4x *SCD4X) Collect() []sensors.MeasurementRecording {
 		Value:   scd4x.data.Humidity,
 		Sensor:  scd4x.Name(),
 	})
-	measurements = append(measurements, sensors.MeasurementRecording{
-		Measure: &sensors.CarbonDioxide,
-		Value:   scd4x.data.CO2,
-		Sensor:  scd4x.Name(),
-	})
+	if scd4x.config.MeasurementMode != SCD4X_MODE_SINGLE_SHOT_RHT_ONLY {
+		measurements = append(measurements, sensors.MeasurementRecording{
+			Measure: &sensors.CarbonDioxide,
+			Value:   scd4x.data.CO2,
+			Sensor:  scd4x.Name(),
+		})
+	}
 	return measurements
 }
 
+// Observe feeds the latest pressure reading of the configured source into the CO2 compensation.
+// Without a reading the pressure corresponding to the altitude is used again.
+func (scd4x *SCD4X) Observe(recordings []sensors.MeasurementRecording) {
+	if scd4x.config.PressureSource == "" {
+		return
+	}
+
+	for _, recording := range recordings {
+		if recording.Measure.ID != sensors.Pressure.ID || !strings.EqualFold(recording.Sensor, scd4x.config.PressureSource) {
+			continue
+		}
+		if recording.Value < 700 || recording.Value > 1200 {
+			log.ErrorLog.Printf("Ignoring pressure %.1f hPa from %s, outside of the supported range", recording.Value, recording.Sensor)
+			break
+		}
+		if err := scd4x.updateAmbientPressure(uint16(math.Round(recording.Value))); err != nil {
+			log.ErrorLog.Printf("Failed to set ambient pressure: %q", err)
+			return
+		}
+		scd4x.pressureCompensated = true
+		return
+	}
+
+	if scd4x.pressureCompensated {
+		log.InfoLog.Printf("No pressure from %s, falling back to the altitude of %dm", scd4x.config.PressureSource, scd4x.altitude)
+		if err := scd4x.updateAmbientPressure(uint16(math.Round(altitudePressure(scd4x.altitude)))); err != nil {
+			log.ErrorLog.Printf("Failed to set ambient pressure: %q", err)
+			return
+		}
+		scd4x.pressureCompensated = false
+	}
+}
+
+// updateAmbientPressure sends the pressure right away during periodic measurements, the single
+// shot modes apply it on the next measurement since the sensor is powered down in between.
+func (scd4x *SCD4X) updateAmbientPressure(ambientPressure uint16) error {
+	if scd4x.singleShot() {
+		scd4x.ambientPressure = ambientPressure
+		return nil
+	}
+	return scd4x.SetAmbientPressure(ambientPressure)
+}
+
+// altitudePressure is the standard atmosphere pressure in hPa at the given altitude in meters.
+func altitudePressure(altitude uint16) float64 {
+	return 1013.25 * math.Pow(1-2.25577e-5*float64(altitude), 5.25588)
+}
+
+func (scd4x *SCD4X) Operations() map[string]sensors.Operation {
+	return map[string]sensors.Operation{
+		"info": {
+			Description: "Serial number and settings",
+			Run: func(args map[string]string) (string, error) {
+				var info string
+				err := scd4x.whileIdle(func() error {
+					altitude, err := scd4x.GetAltitude()
+					if err != nil {
+						return err
+					}
+					offset, err := scd4x.GetTempetatureOffset()
+					if err != nil {
+						return err
+					}
+					asc, err := scd4x.IsCalibrationEnabled()
+					if err != nil {
+						return err
+					}
+					info = fmt.Sprintf("SerialNumber: %s\nAltitude: %dm\nTemperatureOffset: %.2fC\nASC: %t\nMeasurementMode: %s",
+						scd4x.deviceInfo.serialNumber, altitude, offset, asc, scd4x.config.MeasurementMode)
+					return nil
+				})
+				return info, err
+			},
+		},
+		"frc": {
+			Description: "Forced recalibration to the given CO2 concentration, the sensor has to measure it for at least 3 minutes first",
+			Args:        []string{"ppm"},
+			Run: func(args map[string]string) (string, error) {
+				ppm, err := strconv.ParseUint(args["ppm"], 10, 16)
+				if err != nil {
+					return "", fmt.Errorf("invalid ppm %q: %v", args["ppm"], err)
+				}
+				var correction int
+				err = scd4x.whileIdle(func() error {
+					correction, err = scd4x.ForceCalibration(uint16(ppm))
+					return err
+				})
+				return fmt.Sprintf("Recalibrated to %dppm, correction %dppm", ppm, correction), err
+			},
+		},
+		"asc": {
+			Description: "Enable or disable the automatic self calibration, stored on the device only with persist = true",
+			Args:        []string{"enabled"},
+			Run: func(args map[string]string) (string, error) {
+				enable, err := strconv.ParseBool(args["enabled"])
+				if err != nil {
+					return "", fmt.Errorf("invalid enabled %q: %v", args["enabled"], err)
+				}
+				err = scd4x.whileIdle(func() error {
+					if err := scd4x.ToggleCalibration(enable); err != nil {
+						return err
+					}
+					if scd4x.config.Persist {
+						return scd4x.PersistSettings()
+					}
+					return nil
+				})
+				return fmt.Sprintf("ASC: %t", enable), err
+			},
+		},
+		"selftest": {
+			Description: "Self test, takes 10 seconds",
+			Run: func(args map[string]string) (string, error) {
+				return "Self test passed", scd4x.whileIdle(scd4x.Test)
+			},
+		},
+		"factory-reset": {
+			Description: "Reset all settings and the calibration history to the factory defaults",
+			Run: func(args map[string]string) (string, error) {
+				return "Factory reset done", scd4x.whileIdle(scd4x.FactoryReset)
+			},
+		},
+	}
+}
+
+// whileIdle stops the measurements, or wakes the sensor up in the single shot modes, runs fn and
+// resumes measuring afterwards.
+func (scd4x *SCD4X) whileIdle(fn func() error) error {
+	if scd4x.singleShot() {
+		scd4x.WakeUp()
+	} else if err := SCD4X_STOPPERIODICMEASUREMENT.Write(scd4x.device, &scd4x.mu); err != nil {
+		return err
+	}
+
+	err := fn()
+	if startErr := scd4x.startMeasurement(); err == nil {
+		err = startErr
+	}
+	return err
+}
+
 func (scd4x *SCD4X) dataReady() bool {
 	response, err := SCD4X_DATAREADY.Read(scd4x.device, &scd4x.mu)
 	if err != nil {
@@ -129,49 +458,54 @@ func (scd4x *SCD4X) dataReady() bool {
 
 func (scd4x *SCD4X) readData() error {
 	response, err := SCD4X_READMEASUREMENT.Read(scd4x.device, &scd4x.mu)
+	if err != nil {
+		return err
+	}
 	scd4x.data.CO2 = float64(binary.BigEndian.Uint16(response[0:2]))
 	scd4x.data.Temperature = (-45 + 175*(float64(binary.BigEndian.Uint16(response[2:4]))/math.Pow(2, 16)))
 	scd4x.data.Humidity = 100 * (float64(binary.BigEndian.Uint16(response[4:6])) / math.Pow(2, 16))
-	return err
+	return nil
 }
 
-func (scd4x *SCD4X) FactoryReset() {
-	SCD4X_STOPPERIODICMEASUREMENT.Write(scd4x.device, &scd4x.mu)
-	SCD4X_FACTORYRESET.Write(scd4x.device, &scd4x.mu)
+func (scd4x *SCD4X) FactoryReset() error {
+	if err := SCD4X_STOPPERIODICMEASUREMENT.Write(scd4x.device, &scd4x.mu); err != nil {
+		return err
+	}
+	return SCD4X_FACTORYRESET.Write(scd4x.device, &scd4x.mu)
 }
 
-func (scd4x *SCD4X) IsCalibrationEnabled() bool {
+func (scd4x *SCD4X) IsCalibrationEnabled() (bool, error) {
 	response, err := SCD4X_GETASCE.Read(scd4x.device, &scd4x.mu)
 	if err != nil {
-		log.ErrorLog.Printf("Calibration status could not be read %q", err)
-		return false
+		return false, err
 	}
-	return response[1] == 1
+	return response[1] == 1, nil
 }
 
-func (scd4x *SCD4X) ToggleCalibration(enable bool) {
+func (scd4x *SCD4X) ToggleCalibration(enable bool) error {
 	var value uint16
 	if enable {
 		value = 1
 	}
-	SCD4X_SETASCE.WriteUint16(scd4x.device, &scd4x.mu, value)
+	return SCD4X_SETASCE.WriteUint16(scd4x.device, &scd4x.mu, value)
 }
 
-func (scd4x *SCD4X) ForceCalibration(targetCO2 uint16) error {
+// ForceCalibration sets the CO2 reading to targetCO2 and returns the applied correction in ppm.
+func (scd4x *SCD4X) ForceCalibration(targetCO2 uint16) (int, error) {
 	SCD4X_STOPPERIODICMEASUREMENT.Write(scd4x.device, &scd4x.mu)
 	response, err := SCD4X_FORCEDRECAL.ReadUint16(scd4x.device, &scd4x.mu, targetCO2)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	var unpackedData uint16
 	if err = binary.Read(bytes.NewReader(response[0:2]), binary.BigEndian, &unpackedData); err != nil {
-		return err
+		return 0, err
 	}
 
 	if unpackedData == 0xFFFF {
-		return fmt.Errorf("force recalibration failed, please make sure sensor is active for 3m first")
+		return 0, fmt.Errorf("force recalibration failed, please make sure sensor is active for 3m first")
 	}
-	return nil
+	return int(unpackedData) - 0x8000, nil
 }
 
 func (scd4x *SCD4X) Test() error {
@@ -192,26 +526,39 @@ func (scd4x *SCD4X) SerialNumber() error {
 		return err
 	}
 
-	scd4x.deviceInfo.serialNumber = bytesToString(response)
+	scd4x.deviceInfo.serialNumber = fmt.Sprintf("%X", response)
 	return nil
 }
 
-func (scd4x *SCD4X) StartLowPeriodicMeasurement() {
-	SCD4X_STARTLOWPOWERPERIODICMEASUREMENT.Write(scd4x.device, &scd4x.mu)
+func (scd4x *SCD4X) StartLowPeriodicMeasurement() error {
+	return SCD4X_STARTLOWPOWERPERIODICMEASUREMENT.Write(scd4x.device, &scd4x.mu)
+}
+
+// WakeUp brings the sensor from sleep to idle. The sensor does not acknowledge the command, so
+// there is no error to report.
+func (scd4x *SCD4X) WakeUp() {
+	SCD4X_WAKEUP.Write(scd4x.device, &scd4x.mu)
+}
+
+// PowerDown puts the idle sensor to sleep until WakeUp.
+func (scd4x *SCD4X) PowerDown() error {
+	return SCD4X_POWERDOWN.Write(scd4x.device, &scd4x.mu)
 }
 
-func (scd4x *SCD4X) PersistSettings() {
-	SCD4X_PERSISTSETTINGS.Write(scd4x.device, &scd4x.mu)
+func (scd4x *SCD4X) PersistSettings() error {
+	return SCD4X_PERSISTSETTINGS.Write(scd4x.device, &scd4x.mu)
 }
 
+// SetAmbientPressure overrides the altitude compensation, the pressure is in hPa.
+// Can be sent during periodic measurements.
 func (scd4x *SCD4X) SetAmbientPressure(ambientPressure uint16) error {
 	return SCD4X_SETPRESSURE.WriteUint16(scd4x.device, &scd4x.mu, ambientPressure)
 }
 
 /*
 Specifies the offset to be added to the reported measurements to account for a bias in
-the measured signal. Value is in degrees Celsius with a resolution of 0.01 degrees and a
-maximum value of 374 C
+the measured signal. Value is in degrees Celsius with a resolution of 0.01 degrees and
+is stored as the word offset * 2^16 / 175, limiting it to less than 175 C
 .. note::
 
 	This value will NOT be saved and will be reset on boot unless saved with
@@ -223,17 +570,24 @@ func (scd4x *SCD4X) GetTempetatureOffset() (float64, error) {
 		return 0, err
 	}
 	unwrappedvalue := binary.BigEndian.Uint16(response[0:2])
-	return 175.0 * float64(unwrappedvalue) / math.Pow(2, 16), nil
+	return math.Round(175.0*float64(unwrappedvalue)/math.Pow(2, 16)*100) / 100, nil
 }
 
-func (scd4x *SCD4X) SetTemperatureOffset(value uint16) error {
-	if value > 374 {
-		return fmt.Errorf("offset value must be less than or equal to 374 degrees Celsius")
+func (scd4x *SCD4X) SetTemperatureOffset(offset float64) error {
+	if err := validTemperatureOffset(offset); err != nil {
+		return err
 	}
-	temp := value * uint16(math.Pow(2, 16)) / 175
+	temp := uint16(math.Round(offset * math.Pow(2, 16) / 175))
 	return SCD4X_SETTEMPOFFSET.WriteUint16(scd4x.device, &scd4x.mu, temp)
 }
 
+func validTemperatureOffset(offset float64) error {
+	if offset < 0 || math.Round(offset*math.Pow(2, 16)/175) > math.MaxUint16 {
+		return fmt.Errorf("offset value must be between 0 and 175 degrees Celsius")
+	}
+	return nil
+}
+
 /*
 Specifies the altitude at the measurement location in meters above sea level. Setting
 this value adjusts the CO2 measurement calculations to account for the air pressure's effect
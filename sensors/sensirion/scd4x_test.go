@@ -0,0 +1,120 @@
+package sensirion
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"periph.io/x/conn/v3/i2c"
+	"periph.io/x/conn/v3/physic"
+)
+
+// fakeSCD4XBus emulates the settings of a SCD4x, set commands store a word which the matching
+// get command reads back.
+type fakeSCD4XBus struct {
+	words   map[uint16]uint16 // Keyed by the get command
+	pending uint16            // Last command written, answered by the next read
+}
+
+var fakeSCD4XSettings = map[uint16]uint16{
+	SCD4X_SETTEMPOFFSET.code: SCD4X_GETTEMPOFFSET.code,
+	SCD4X_SETALTITUDE.code:   SCD4X_GETALTITUDE.code,
+}
+
+func newFakeSCD4X() (*SCD4X, *fakeSCD4XBus) {
+	bus := &fakeSCD4XBus{words: make(map[uint16]uint16)}
+	return &SCD4X{device: &i2c.Dev{Addr: 0x62, Bus: bus}}, bus
+}
+
+func (f *fakeSCD4XBus) String() string {
+	return "fake scd4x"
+}
+
+func (f *fakeSCD4XBus) SetSpeed(physic.Frequency) error {
+	return nil
+}
+
+func (f *fakeSCD4XBus) Tx(addr uint16, w, r []byte) error {
+	if len(w) >= 2 {
+		code := binary.BigEndian.Uint16(w[0:2])
+		if get, ok := fakeSCD4XSettings[code]; ok {
+			if len(w) != 5 || crc8(w[2:4]) != w[4] {
+				return fmt.Errorf("malformed write % x", w)
+			}
+			f.words[get] = binary.BigEndian.Uint16(w[2:4])
+			return nil
+		}
+		f.pending = code
+	}
+	if len(r) > 0 {
+		if len(r) != 3 {
+			return fmt.Errorf("unexpected read of %d bytes", len(r))
+		}
+		binary.BigEndian.PutUint16(r[0:2], f.words[f.pending])
+		r[2] = crc8(r[0:2])
+	}
+	return nil
+}
+
+func TestSCD4XSetTemperatureOffsetEncoding(t *testing.T) {
+	tests := []struct {
+		offset float64
+		word   uint16
+	}{
+		{0, 0},
+		{2.35, 880},
+		{4, 1498},
+		{10, 3745},
+		{174.99, 65532},
+	}
+	for _, test := range tests {
+		scd4x, bus := newFakeSCD4X()
+		if err := scd4x.SetTemperatureOffset(test.offset); err != nil {
+			t.Fatalf("SetTemperatureOffset(%v) failed: %v", test.offset, err)
+		}
+		if word := bus.words[SCD4X_GETTEMPOFFSET.code]; word != test.word {
+			t.Errorf("SetTemperatureOffset(%v) wrote %d, want %d", test.offset, word, test.word)
+		}
+	}
+}
+
+func TestSCD4XTemperatureOffsetRoundTrip(t *testing.T) {
+	for _, offset := range []float64{0, 0.01, 2.35, 4, 7.5, 20.42, 100} {
+		scd4x, _ := newFakeSCD4X()
+		if err := scd4x.SetTemperatureOffset(offset); err != nil {
+			t.Fatalf("SetTemperatureOffset(%v) failed: %v", offset, err)
+		}
+		got, err := scd4x.GetTempetatureOffset()
+		if err != nil {
+			t.Fatalf("GetTempetatureOffset failed: %v", err)
+		}
+		if got != offset {
+			t.Errorf("GetTempetatureOffset() = %v after setting %v", got, offset)
+		}
+	}
+}
+
+func TestSCD4XSetTemperatureOffsetOutOfRange(t *testing.T) {
+	for _, offset := range []float64{-0.5, 175, 374} {
+		scd4x, bus := newFakeSCD4X()
+		if err := scd4x.SetTemperatureOffset(offset); err == nil {
+			t.Errorf("SetTemperatureOffset(%v) succeeded, want an error", offset)
+		}
+		if _, ok := bus.words[SCD4X_GETTEMPOFFSET.code]; ok {
+			t.Errorf("SetTemperatureOffset(%v) wrote to the device", offset)
+		}
+	}
+}
+
+// A failed read, e.g. a NACK right after waking up, keeps the previous measurement.
+func TestSCD4XReadDataError(t *testing.T) {
+	scd4x, _ := newFakeSCD4X()
+	scd4x.data.CO2 = 420
+	// The fake bus only answers single words, the 3 words of the measurement fail.
+	if err := scd4x.readData(); err == nil {
+		t.Fatal("readData succeeded, want an error")
+	}
+	if scd4x.data.CO2 != 420 {
+		t.Errorf("CO2 = %v after a failed read, want the previous 420", scd4x.data.CO2)
+	}
+}
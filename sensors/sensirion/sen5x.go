@@ -3,6 +3,7 @@ package sensirion
 import (
 	"encoding/binary"
 	"fmt"
+	"math"
 	"strings"
 	"sync"
 	"time"
@@ -13,14 +14,20 @@ import (
 )
 
 var (
-	SEN5X_RESET             = Command{code: 0xD304, description: "Reset device", delay: time.Duration(100 * time.Millisecond), size: 0}
-	SEN5X_SERIAL_NUMBER     = Command{code: 0xD033, description: "Serial number", delay: time.Duration(20 * time.Millisecond), size: 32}
-	SEN5X_PRODUCT_NAME      = Command{code: 0xD014, description: "Product name", delay: time.Duration(20 * time.Millisecond), size: 32}
-	SEN5X_VERSION           = Command{code: 0xD100, description: "Versions", delay: time.Duration(20 * time.Millisecond), size: 8}
-	SEN5X_READ_STATUS       = Command{code: 0xD206, description: "Read status", delay: time.Duration(20 * time.Millisecond), size: 4}
-	SEN5X_START_MEASUREMENT = Command{code: 0x0021, description: "Start measurement", delay: time.Duration(50 * time.Millisecond), size: 0}
-	SEN5X_READ_MEASUREMENTS = Command{code: 0x03C4, description: "Read measurements", delay: time.Duration(20 * time.Millisecond), size: 16}
-	SEN5X_RW_TEMP_OFFSET    = Command{code: 0x60B2, description: "Read/Write Temperature compensation", delay: time.Duration(20 * time.Millisecond), size: 16}
+	SEN5X_RESET              = Command{code: 0xD304, description: "Reset device", delay: time.Duration(100 * time.Millisecond), size: 0}
+	SEN5X_SERIAL_NUMBER      = Command{code: 0xD033, description: "Serial number", delay: time.Duration(20 * time.Millisecond), size: 32}
+	SEN5X_PRODUCT_NAME       = Command{code: 0xD014, description: "Product name", delay: time.Duration(20 * time.Millisecond), size: 32}
+	SEN5X_VERSION            = Command{code: 0xD100, description: "Versions", delay: time.Duration(20 * time.Millisecond), size: 8}
+	SEN5X_READ_STATUS        = Command{code: 0xD206, description: "Read status", delay: time.Duration(20 * time.Millisecond), size: 4}
+	SEN5X_START_MEASUREMENT  = Command{code: 0x0021, description: "Start measurement", delay: time.Duration(50 * time.Millisecond), size: 0}
+	SEN5X_READ_MEASUREMENTS  = Command{code: 0x03C4, description: "Read measurements", delay: time.Duration(20 * time.Millisecond), size: 16}
+	SEN5X_READ_RAW_VALUES    = Command{code: 0x03D2, description: "Read raw values", delay: time.Duration(20 * time.Millisecond), size: 8}
+	SEN5X_READ_PM_VALUES     = Command{code: 0x0413, description: "Read measured PM values", delay: time.Duration(20 * time.Millisecond), size: 20}
+	SEN5X_RW_TEMP_OFFSET     = Command{code: 0x60B2, description: "Read/Write Temperature compensation", delay: time.Duration(20 * time.Millisecond), size: 6}
+	SEN5X_RW_WARM_START      = Command{code: 0x60C6, description: "Read/Write warm start parameter", delay: time.Duration(20 * time.Millisecond), size: 2}
+	SEN5X_RW_RHT_ACCEL_MODE  = Command{code: 0x60F7, description: "Read/Write RH/T acceleration mode", delay: time.Duration(20 * time.Millisecond), size: 2}
+	SEN5X_START_FAN_CLEANING = Command{code: 0x5607, description: "Start fan cleaning", delay: time.Duration(20 * time.Millisecond), size: 0}
+	SEN5X_RW_AUTO_CLEANING   = Command{code: 0x8004, description: "Read/Write auto cleaning interval", delay: time.Duration(20 * time.Millisecond), size: 4}
 )
 
 type SEN5XDeviceInfo struct {
@@ -35,7 +42,103 @@ type SEN5XDeviceInfo struct {
 	protocolMajorVersion uint8
 	protocolMinorVersion uint8
 
-	status uint32
+	status SEN5XStatus
+}
+
+// The fan runs at full speed for this long after a cleaning starts, skewing the PM readings.
+const sen5xFanCleaningDuration = 10 * time.Second
+
+// RH/T acceleration modes, adapting the humidity and temperature compensation to the enclosure.
+const (
+	SEN5X_RHT_ACCELERATION_LOW    = "low"
+	SEN5X_RHT_ACCELERATION_HIGH   = "high"
+	SEN5X_RHT_ACCELERATION_MEDIUM = "medium"
+)
+
+// Values of the acceleration modes on the device.
+var sen5xRHTAccelerationModes = []string{SEN5X_RHT_ACCELERATION_LOW, SEN5X_RHT_ACCELERATION_HIGH, SEN5X_RHT_ACCELERATION_MEDIUM}
+
+// SEN5XTemperatureCompensation corrects the temperature for the heat of the device, the
+// compensation is Offset + Slope * temperature, applied gradually over TimeConstant seconds
+// (0 applies it immediately).
+type SEN5XTemperatureCompensation struct {
+	Offset       float64 // Degrees Celsius
+	Slope        float64
+	TimeConstant uint16 `toml:"time_constant"`
+}
+
+func (compensation SEN5XTemperatureCompensation) words() []uint16 {
+	return []uint16{
+		uint16(int16(math.Round(compensation.Offset * 200))),
+		uint16(int16(math.Round(compensation.Slope * 10000))),
+		compensation.TimeConstant,
+	}
+}
+
+// SEN5XConfig holds the options of the [sensors.sen5x] section. Unset options keep the value
+// stored on the device.
+type SEN5XConfig struct {
+	// Seconds between two automatic fan cleanings, 0 disables them. The device default is a week.
+	AutoCleaningInterval *uint32 `toml:"auto_cleaning_interval"`
+	// File keeping the VOC algorithm state across restarts, so the VOC index does not have to
+	// learn the baseline again. Saved every VOCStateSaveInterval and on shutdown, restored when
+	// younger than VOCStateMaxAge.
+	VOCStateFile         string        `toml:"voc_state_file"`
+	VOCStateSaveInterval time.Duration `toml:"voc_state_save_interval"`
+	VOCStateMaxAge       time.Duration `toml:"voc_state_max_age"`
+	VOCTuning            *SEN5XTuning  `toml:"voc_tuning"`
+	NOxTuning            *SEN5XTuning  `toml:"nox_tuning"`
+
+	TemperatureCompensation *SEN5XTemperatureCompensation `toml:"temperature_compensation"`
+	// Shortens the RH/T stabilization after a restart of a warm device, 0 (cold start, default)
+	// to 65535 (warm start).
+	WarmStart *uint16 `toml:"warm_start"`
+	// low (default), medium or high, depending on how fast the enclosure follows the ambient.
+	RHTAccelerationMode string `toml:"rht_acceleration_mode"`
+
+	// Also report the humidity and temperature before compensation and the raw gas signals.
+	RawSignals bool `toml:"raw_signals"`
+	// Also report the cumulative particle number concentrations and the typical particle size, needs a
+	// firmware supporting the read measured PM values command.
+	NumberConcentrations bool `toml:"number_concentrations"`
+}
+
+// SEN5XStatus is the device status register, see 5.4 of the datasheet.
+type SEN5XStatus uint32
+
+type sen5xStatusFlag struct {
+	bit   uint
+	name  string
+	fault bool // Informational flags like the fan cleaning are not faults
+	rht   bool // Only reported by the variants with the RH/T and gas sensors
+}
+
+var sen5xFanCleaning = sen5xStatusFlag{bit: 19, name: "fan_cleaning"}
+
+var sen5xStatusFlags = []sen5xStatusFlag{
+	{bit: 21, name: "fan_speed_warning", fault: true},
+	sen5xFanCleaning,
+	{bit: 7, name: "gas_sensor_error", fault: true, rht: true},
+	{bit: 6, name: "rht_error", fault: true, rht: true},
+	{bit: 5, name: "laser_failure", fault: true},
+	{bit: 4, name: "fan_failure", fault: true},
+}
+
+func (status SEN5XStatus) has(flag sen5xStatusFlag) bool {
+	return status&(1<<flag.bit) != 0
+}
+
+func (status SEN5XStatus) String() string {
+	active := make([]string, 0)
+	for _, flag := range sen5xStatusFlags {
+		if status.has(flag) {
+			active = append(active, flag.name)
+		}
+	}
+	if len(active) == 0 {
+		return "ok"
+	}
+	return strings.Join(active, ", ")
 }
 
 type SEN5XMeasurement struct {
@@ -47,14 +150,28 @@ type SEN5XMeasurement struct {
 	PM2_5       float64
 	PM4_0       float64
 	PM10        float64
+
+	RawHumidity    float64
+	RawTemperature float64
+	RawVOC         float64
+	RawNOx         float64
+
+	NumberConcentrations [5]float64 // PM0.5, PM1.0, PM2.5, PM4.0 and PM10
+	TypicalParticleSize  float64
 }
 
 type SEN5X struct {
 	device *i2c.Dev
 	mu     sync.Mutex
 
+	config     SEN5XConfig
 	deviceInfo SEN5XDeviceInfo
 	data       SEN5XMeasurement
+
+	cleaningUntil time.Time // End of the last fan cleaning started by us
+
+	stateMu    sync.Mutex // Serializes the VOC state saves of Collect and Close
+	stateSaved time.Time
 }
 
 func init() {
@@ -78,62 +195,196 @@ func (sen5x *SEN5X) Collect() []sensors.MeasurementRecording {
 		return measurements
 	}
 
-	sen5x.data.PM1_0 = float64(binary.BigEndian.Uint16(data[0:2])) / 10
-	sen5x.data.PM2_5 = float64(binary.BigEndian.Uint16(data[2:4])) / 10
-	sen5x.data.PM4_0 = float64(binary.BigEndian.Uint16(data[4:6])) / 10
-	sen5x.data.PM10 = float64(binary.BigEndian.Uint16(data[6:8])) / 10
-	sen5x.data.Humidity = float64(int16(binary.BigEndian.Uint16(data[8:10]))) / 100
-	sen5x.data.Temperature = float64(int16(binary.BigEndian.Uint16(data[10:12]))) / 200
-	sen5x.data.VOCIndex = float64(int16(binary.BigEndian.Uint16(data[12:14]))) / 10
-	sen5x.data.NOxIndex = float64(int16(binary.BigEndian.Uint16(data[14:16]))) / 10
-
-	measurements = append(measurements, sensors.MeasurementRecording{
-		Measure: &sensors.Humidity,
-		Value:   sen5x.data.Humidity,
-		Sensor:  sen5x.deviceInfo.productName,
-	})
-	measurements = append(measurements, sensors.MeasurementRecording{
-		Measure: &sensors.Temperature,
-		Value:   sen5x.data.Temperature,
-		Sensor:  sen5x.deviceInfo.productName,
-	})
-	measurements = append(measurements, sensors.MeasurementRecording{
-		Measure: &sensors.NOx,
-		Value:   sen5x.data.NOxIndex,
-		Sensor:  sen5x.deviceInfo.productName,
-	})
-	measurements = append(measurements, sensors.MeasurementRecording{
-		Measure: &sensors.VOC,
-		Value:   sen5x.data.VOCIndex,
-		Sensor:  sen5x.deviceInfo.productName,
-	})
-	measurements = append(measurements, sensors.MeasurementRecording{
-		Measure:  &sensors.ParticleMatterEnvironmental,
-		Value:    sen5x.data.PM1_0,
-		Sensor:   sen5x.deviceInfo.productName,
-		Metadata: map[sensors.Metadata]string{sensors.ParticleConcentration: "1.0pm"},
-	})
-	measurements = append(measurements, sensors.MeasurementRecording{
-		Measure:  &sensors.ParticleMatterEnvironmental,
-		Value:    sen5x.data.PM2_5,
-		Sensor:   sen5x.deviceInfo.productName,
-		Metadata: map[sensors.Metadata]string{sensors.ParticleConcentration: "2.5pm"},
-	})
-	measurements = append(measurements, sensors.MeasurementRecording{
-		Measure:  &sensors.ParticleMatterEnvironmental,
-		Value:    sen5x.data.PM4_0,
-		Sensor:   sen5x.deviceInfo.productName,
-		Metadata: map[sensors.Metadata]string{sensors.ParticleConcentration: "4.0pm"},
-	})
-	measurements = append(measurements, sensors.MeasurementRecording{
-		Measure:  &sensors.ParticleMatterEnvironmental,
-		Value:    sen5x.data.PM10,
-		Sensor:   sen5x.deviceInfo.productName,
-		Metadata: map[sensors.Metadata]string{sensors.ParticleConcentration: "10pm"},
-	})
+	sen5x.data.PM1_0 = unsignedValue(data[0:2], 10)
+	sen5x.data.PM2_5 = unsignedValue(data[2:4], 10)
+	sen5x.data.PM4_0 = unsignedValue(data[4:6], 10)
+	sen5x.data.PM10 = unsignedValue(data[6:8], 10)
+	sen5x.data.Humidity = signedValue(data[8:10], 100)
+	sen5x.data.Temperature = signedValue(data[10:12], 200)
+	sen5x.data.VOCIndex = signedValue(data[12:14], 10)
+	sen5x.data.NOxIndex = signedValue(data[14:16], 10)
+
+	model := sen5x.model()
+	measurements = append(measurements, sen5x.statusRecordings(model)...)
+	cleaning := sen5x.cleaning()
+	record := func(measure *sensors.Measurement, value float64, metadata map[sensors.Metadata]string) {
+		if math.IsNaN(value) || !model.supports(measure) {
+			return
+		}
+		if cleaning && particleMeasurement(measure) {
+			return
+		}
+		measurements = append(measurements, sensors.MeasurementRecording{
+			Measure:  measure,
+			Value:    value,
+			Sensor:   sen5x.deviceInfo.productName,
+			Metadata: metadata,
+		})
+	}
+
+	record(&sensors.Humidity, sen5x.data.Humidity, nil)
+	record(&sensors.Temperature, sen5x.data.Temperature, nil)
+	record(&sensors.NOx, sen5x.data.NOxIndex, nil)
+	record(&sensors.VOC, sen5x.data.VOCIndex, nil)
+	record(&sensors.ParticleMatterEnvironmental, sen5x.data.PM1_0, map[sensors.Metadata]string{sensors.ParticleConcentration: "1.0pm"})
+	record(&sensors.ParticleMatterEnvironmental, sen5x.data.PM2_5, map[sensors.Metadata]string{sensors.ParticleConcentration: "2.5pm"})
+	record(&sensors.ParticleMatterEnvironmental, sen5x.data.PM4_0, map[sensors.Metadata]string{sensors.ParticleConcentration: "4.0pm"})
+	record(&sensors.ParticleMatterEnvironmental, sen5x.data.PM10, map[sensors.Metadata]string{sensors.ParticleConcentration: "10pm"})
+
+	if sen5x.config.RawSignals {
+		if err := sen5x.readRawValues(); err != nil {
+			log.ErrorLog.Printf("Failed to read raw values: %q", err)
+		} else {
+			record(&sensors.UncompensatedHumidity, sen5x.data.RawHumidity, nil)
+			record(&sensors.UncompensatedTemperature, sen5x.data.RawTemperature, nil)
+			record(&sensors.GasRawSignal, sen5x.data.RawVOC, map[sensors.Metadata]string{sensors.Gas: "voc"})
+			record(&sensors.GasRawSignal, sen5x.data.RawNOx, map[sensors.Metadata]string{sensors.Gas: "nox"})
+		}
+	}
+	if sen5x.config.NumberConcentrations {
+		if err := sen5x.readNumberConcentrations(); err != nil {
+			log.ErrorLog.Printf("Failed to read number concentrations, disabling them: %q", err)
+			sen5x.config.NumberConcentrations = false
+		} else {
+			// The SEN5x counts the particles from 0.3um up to the size while the Plantower sensors
+			// count the ones larger than the size, the sizes state the range so the series differ.
+			for i, size := range []string{"0.3-0.5um", "0.3-1um", "0.3-2.5um", "0.3-4.0um", "0.3-10um"} {
+				record(&sensors.ParticleCount, sen5x.data.NumberConcentrations[i], map[sensors.Metadata]string{sensors.ParticleSize: size})
+			}
+			record(&sensors.TypicalParticleSize, sen5x.data.TypicalParticleSize, nil)
+		}
+	}
+
+	sen5x.saveVOCStatePeriodically()
 	return measurements
 }
 
+// readRawValues reads the humidity and temperature before compensation and the gas sensor ticks.
+func (sen5x *SEN5X) readRawValues() error {
+	data, err := SEN5X_READ_RAW_VALUES.Read(sen5x.device, &sen5x.mu)
+	if err != nil {
+		return err
+	}
+	sen5x.data.RawHumidity = signedValue(data[0:2], 100)
+	sen5x.data.RawTemperature = signedValue(data[2:4], 200)
+	sen5x.data.RawVOC = unsignedValue(data[4:6], 1)
+	sen5x.data.RawNOx = unsignedValue(data[6:8], 1)
+	return nil
+}
+
+// readNumberConcentrations reads the cumulative particle counts from 0.3um to 0.5um up to 10um, in
+// particles per 0.1L like the Plantower sensors, and the typical particle size.
+func (sen5x *SEN5X) readNumberConcentrations() error {
+	data, err := SEN5X_READ_PM_VALUES.Read(sen5x.device, &sen5x.mu)
+	if err != nil {
+		return err
+	}
+	// Words 0-3 repeat the mass concentrations, followed by the number concentrations in #/cm³.
+	for i := range sen5x.data.NumberConcentrations {
+		sen5x.data.NumberConcentrations[i] = unsignedValue(data[8+2*i:10+2*i], 10) * 100
+	}
+	sen5x.data.TypicalParticleSize = unsignedValue(data[18:20], 1000)
+	return nil
+}
+
+func particleMeasurement(measure *sensors.Measurement) bool {
+	return measure.ID == sensors.ParticleMatterEnvironmental.ID ||
+		measure.ID == sensors.ParticleCount.ID ||
+		measure.ID == sensors.TypicalParticleSize.ID
+}
+
+// cleaning reports whether the fan cleaning is running, either started by the device on its
+// interval or by us. The PM readings are dropped meanwhile.
+func (sen5x *SEN5X) cleaning() bool {
+	return sen5x.deviceInfo.status.has(sen5xFanCleaning) || time.Now().Before(sen5x.cleaningUntil)
+}
+
+// statusRecordings polls the device status and reports each flag, logging the flags that got set
+// or cleared since the last poll.
+func (sen5x *SEN5X) statusRecordings(model SEN5XModel) []sensors.MeasurementRecording {
+	previous := sen5x.deviceInfo.status
+	if err := sen5x.Status(); err != nil {
+		log.ErrorLog.Printf("Failed to read status: %q", err)
+		return nil
+	}
+	status := sen5x.deviceInfo.status
+
+	recordings := make([]sensors.MeasurementRecording, 0, len(sen5xStatusFlags))
+	for _, flag := range sen5xStatusFlags {
+		if flag.rht && model == SEN50 {
+			continue
+		}
+		active := status.has(flag)
+		if active != previous.has(flag) {
+			switch {
+			case active && flag.fault:
+				log.ErrorLog.Printf("%s reports %s", sen5x.deviceInfo.productName, flag.name)
+			case active:
+				log.InfoLog.Printf("%s reports %s", sen5x.deviceInfo.productName, flag.name)
+			default:
+				log.InfoLog.Printf("%s cleared %s", sen5x.deviceInfo.productName, flag.name)
+			}
+		}
+
+		var value float64
+		if active {
+			value = 1
+		}
+		recordings = append(recordings, sensors.MeasurementRecording{
+			Measure:  &sensors.SensorStatus,
+			Value:    value,
+			Sensor:   sen5x.deviceInfo.productName,
+			Metadata: map[sensors.Metadata]string{sensors.Status: flag.name},
+		})
+	}
+	return recordings
+}
+
+// SEN5XModel tells the variants apart, they share the protocol but not the sensing elements.
+type SEN5XModel string
+
+const (
+	SEN50 SEN5XModel = "SEN50" // PM only
+	SEN54 SEN5XModel = "SEN54" // PM, RH/T and VOC
+	SEN55 SEN5XModel = "SEN55" // PM, RH/T, VOC and NOx
+)
+
+func (sen5x *SEN5X) model() SEN5XModel {
+	return SEN5XModel(strings.ToUpper(strings.TrimSpace(sen5x.deviceInfo.productName)))
+}
+
+// supports reports whether the variant measures the given measurement, unknown variants are
+// assumed to measure everything and rely on the invalid values being dropped.
+func (model SEN5XModel) supports(measure *sensors.Measurement) bool {
+	switch model {
+	case SEN50:
+		return particleMeasurement(measure)
+	case SEN54:
+		return measure.ID != sensors.NOx.ID
+	default:
+		return true
+	}
+}
+
+// unsignedValue decodes a scaled unsigned word, 0xFFFF marks a value as unavailable.
+func unsignedValue(data []byte, scale float64) float64 {
+	raw := binary.BigEndian.Uint16(data)
+	if raw == 0xFFFF {
+		return math.NaN()
+	}
+	return float64(raw) / scale
+}
+
+// signedValue decodes a scaled signed word, 0x7FFF marks a value as unavailable, e.g. the NOx
+// index during the first seconds after starting the measurements or on variants without it.
+func signedValue(data []byte, scale float64) float64 {
+	raw := int16(binary.BigEndian.Uint16(data))
+	if raw == math.MaxInt16 {
+		return math.NaN()
+	}
+	return float64(raw) / scale
+}
+
 func (sen5x *SEN5X) Initialize(bus i2c.Bus, addr uint16) {
 	sen5x.device = &i2c.Dev{Addr: addr, Bus: bus}
 	if err := sen5x.Reset(); err != nil {
@@ -148,6 +399,9 @@ func (sen5x *SEN5X) Initialize(bus i2c.Bus, addr uint16) {
 		log.ErrorLog.Printf("Failed to retrieve product name: %q", err)
 		return
 	}
+	if model := sen5x.model(); model != SEN50 && model != SEN54 && model != SEN55 {
+		log.ErrorLog.Printf("Unknown SEN5x variant %q, reporting every measurement it provides", model)
+	}
 	if err := sen5x.SerialNumber(); err != nil {
 		log.ErrorLog.Printf("Failed to retrieve serial number: %q", err)
 		return
@@ -157,43 +411,235 @@ func (sen5x *SEN5X) Initialize(bus i2c.Bus, addr uint16) {
 		return
 	}
 
-	log.InfoLog.Printf(`Sensirion SEN5x
-	ProductName: %s
-	SerialNumber: %s
-	Status: %d
-	FirmwareDebug: %t
-	Versions:
-		Firmware: %d.%d
-		Hardware: %d.%d
-		Protocol: %d.%d`,
+	log.InfoLog.Printf("Sensirion SEN5x\n\t%s", strings.ReplaceAll(sen5x.info(), "\n", "\n\t"))
+
+	if err := sen5x.applyConfig(); err != nil {
+		log.ErrorLog.Printf("Failed to configure device: %q", err)
+	}
+
+	if err := SEN5X_START_MEASUREMENT.Write(sen5x.device, &sen5x.mu); err != nil {
+		log.ErrorLog.Printf("Failed to start measurements: %q", err)
+		return
+	}
+}
+
+func (sen5x *SEN5X) Configure(decode func(v interface{}) error) error {
+	if err := decode(&sen5x.config); err != nil {
+		return err
+	}
+
+	if sen5x.config.RHTAccelerationMode != "" {
+		if _, err := rhtAccelerationMode(sen5x.config.RHTAccelerationMode); err != nil {
+			return err
+		}
+	}
+	if compensation := sen5x.config.TemperatureCompensation; compensation != nil {
+		if math.Abs(compensation.Offset*200) > math.MaxInt16 || math.Abs(compensation.Slope*10000) > math.MaxInt16 {
+			return fmt.Errorf("temperature compensation out of range: %+v", *compensation)
+		}
+	}
+	return nil
+}
+
+func rhtAccelerationMode(name string) (uint16, error) {
+	for value, mode := range sen5xRHTAccelerationModes {
+		if mode == name {
+			return uint16(value), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown RH/T acceleration mode %q, supported: %s", name, strings.Join(sen5xRHTAccelerationModes, ", "))
+}
+
+// applyConfig writes the configured settings which differ from the ones on the device and reads
+// them back, then restores the VOC state. The measurements have to be stopped.
+func (sen5x *SEN5X) applyConfig() error {
+	if err := sen5x.applyTuning(); err != nil {
+		return err
+	}
+	if err := sen5x.restoreVOCState(); err != nil {
+		log.ErrorLog.Printf("Failed to restore VOC state: %q", err)
+	}
+
+	if compensation := sen5x.config.TemperatureCompensation; compensation != nil {
+		if err := sen5x.applyWords(&SEN5X_RW_TEMP_OFFSET, compensation.words()); err != nil {
+			return err
+		}
+		log.InfoLog.Printf("%s temperature compensation: %+v", sen5x.deviceInfo.productName, *compensation)
+	}
+	if sen5x.config.WarmStart != nil {
+		if err := sen5x.applyWords(&SEN5X_RW_WARM_START, []uint16{*sen5x.config.WarmStart}); err != nil {
+			return err
+		}
+		log.InfoLog.Printf("%s warm start: %d", sen5x.deviceInfo.productName, *sen5x.config.WarmStart)
+	}
+	if sen5x.config.RHTAccelerationMode != "" {
+		mode, err := rhtAccelerationMode(sen5x.config.RHTAccelerationMode)
+		if err != nil {
+			return err
+		}
+		if err := sen5x.applyWords(&SEN5X_RW_RHT_ACCEL_MODE, []uint16{mode}); err != nil {
+			return err
+		}
+		log.InfoLog.Printf("%s RH/T acceleration mode: %s", sen5x.deviceInfo.productName, sen5x.config.RHTAccelerationMode)
+	}
+
+	if sen5x.config.AutoCleaningInterval != nil {
+		interval, err := sen5x.GetAutoCleaningInterval()
+		if err != nil {
+			return err
+		}
+		if interval != *sen5x.config.AutoCleaningInterval {
+			if err := sen5x.SetAutoCleaningInterval(*sen5x.config.AutoCleaningInterval); err != nil {
+				return err
+			}
+			if interval, err = sen5x.GetAutoCleaningInterval(); err != nil {
+				return err
+			}
+			if interval != *sen5x.config.AutoCleaningInterval {
+				return fmt.Errorf("auto cleaning interval is %ds after setting %ds", interval, *sen5x.config.AutoCleaningInterval)
+			}
+		}
+		log.InfoLog.Printf("%s auto cleaning interval: %ds", sen5x.deviceInfo.productName, interval)
+	}
+	return nil
+}
+
+// applyWords writes the words of a read/write setting when they differ from the ones on the
+// device and verifies them by reading them back.
+func (sen5x *SEN5X) applyWords(cmd *Command, words []uint16) error {
+	current, err := sen5x.readWords(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %q", cmd.description, err)
+	}
+	if equalWords(current, words) {
+		return nil
+	}
+	if err := cmd.WriteWords(sen5x.device, &sen5x.mu, words...); err != nil {
+		return err
+	}
+	if current, err = sen5x.readWords(cmd); err != nil {
+		return fmt.Errorf("failed to read %s: %q", cmd.description, err)
+	}
+	if !equalWords(current, words) {
+		return fmt.Errorf("%s is %v after setting %v", cmd.description, current, words)
+	}
+	return nil
+}
+
+func (sen5x *SEN5X) readWords(cmd *Command) ([]uint16, error) {
+	data, err := cmd.Read(sen5x.device, &sen5x.mu)
+	if err != nil {
+		return nil, err
+	}
+	words := make([]uint16, len(data)/2)
+	for i := range words {
+		words[i] = binary.BigEndian.Uint16(data[2*i : 2*i+2])
+	}
+	return words, nil
+}
+
+func equalWords(a, b []uint16) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (sen5x *SEN5X) info() string {
+	return fmt.Sprintf(`ProductName: %s
+SerialNumber: %s
+Status: %s
+FirmwareDebug: %t
+Versions:
+	Firmware: %d.%d
+	Hardware: %d.%d
+	Protocol: %d.%d`,
 		sen5x.deviceInfo.productName, sen5x.deviceInfo.serialNumber,
 		sen5x.deviceInfo.status, sen5x.deviceInfo.firmwareDebug,
 		sen5x.deviceInfo.firmwareMajorVersion, sen5x.deviceInfo.firmwareMinorVersion,
 		sen5x.deviceInfo.hardwareMajorVersion, sen5x.deviceInfo.hardwareMinorVersion,
 		sen5x.deviceInfo.protocolMajorVersion, sen5x.deviceInfo.protocolMinorVersion)
+}
 
-	if err := SEN5X_START_MEASUREMENT.Write(sen5x.device, &sen5x.mu); err != nil {
-		log.ErrorLog.Printf("Failed to start measurements: %q", err)
-		return
+func (sen5x *SEN5X) Operations() map[string]sensors.Operation {
+	return map[string]sensors.Operation{
+		"info": {
+			Description: "Product name, serial number, versions and status",
+			Run: func(args map[string]string) (string, error) {
+				if err := sen5x.Status(); err != nil {
+					return "", err
+				}
+				return sen5x.info(), nil
+			},
+		},
+		"fan-cleaning": {
+			Description: "Run the fan at full speed for 10 seconds to blow out dust, the PM readings are off meanwhile",
+			Run: func(args map[string]string) (string, error) {
+				if err := sen5x.StartFanCleaning(); err != nil {
+					return "", err
+				}
+				return "Fan cleaning started", nil
+			},
+		},
+		"reset": {
+			Description: "Reset the device and restart the measurements, keeping the settings and the learned VOC state",
+			Run: func(args map[string]string) (string, error) {
+				if err := sen5x.reset(); err != nil {
+					return "", err
+				}
+				return "Reset done", nil
+			},
+		},
 	}
 }
 
-func (sen5x *SEN5X) SetTemperatureOffset(offset float32) error {
-	var slope int16 = 0
-	var defaultTimeConstant uint16 = 0
-	var tempOffsetTicks int16 = int16(offset * 200)
+func (sen5x *SEN5X) GetTemperatureCompensation() (SEN5XTemperatureCompensation, error) {
+	words, err := sen5x.readWords(&SEN5X_RW_TEMP_OFFSET)
+	if err != nil {
+		return SEN5XTemperatureCompensation{}, fmt.Errorf("failed to read temperature compensation: %q", err)
+	}
+	return SEN5XTemperatureCompensation{
+		Offset:       float64(int16(words[0])) / 200,
+		Slope:        float64(int16(words[1])) / 10000,
+		TimeConstant: words[2],
+	}, nil
+}
 
-	buffer := make([]uint8, 11)
-	binary.BigEndian.PutUint16(buffer[0:2], SEN5X_RW_TEMP_OFFSET.code)
+// SetTemperatureCompensation only works while the measurements are stopped.
+func (sen5x *SEN5X) SetTemperatureCompensation(compensation SEN5XTemperatureCompensation) error {
+	if err := SEN5X_RW_TEMP_OFFSET.WriteWords(sen5x.device, &sen5x.mu, compensation.words()...); err != nil {
+		return fmt.Errorf("unable to write temperature compensation: %q", err)
+	}
+	return nil
+}
 
-	idx := 2
-	idx = valueBigEndianEncode(uint16(tempOffsetTicks), buffer, idx)
-	idx = valueBigEndianEncode(uint16(slope), buffer, idx)
-	valueBigEndianEncode(uint16(defaultTimeConstant), buffer, idx)
+// StartFanCleaning runs the fan at full speed to blow out dust, only possible while measuring.
+func (sen5x *SEN5X) StartFanCleaning() error {
+	if err := SEN5X_START_FAN_CLEANING.Write(sen5x.device, &sen5x.mu); err != nil {
+		return err
+	}
+	sen5x.cleaningUntil = time.Now().Add(sen5xFanCleaningDuration)
+	return nil
+}
 
-	_, err := sen5x.device.Write(buffer)
+func (sen5x *SEN5X) GetAutoCleaningInterval() (uint32, error) {
+	data, err := SEN5X_RW_AUTO_CLEANING.Read(sen5x.device, &sen5x.mu)
 	if err != nil {
-		return fmt.Errorf("unable to write temperature offset: %q", err)
+		return 0, fmt.Errorf("failed to read auto cleaning interval: %q", err)
+	}
+	return binary.BigEndian.Uint32(data), nil
+}
+
+// SetAutoCleaningInterval sets the seconds between two fan cleanings, 0 disables them. Firmware
+// before 2.0 forgets the interval when powered off.
+func (sen5x *SEN5X) SetAutoCleaningInterval(seconds uint32) error {
+	if err := SEN5X_RW_AUTO_CLEANING.WriteUint32(sen5x.device, &sen5x.mu, seconds); err != nil {
+		return fmt.Errorf("unable to write auto cleaning interval: %q", err)
 	}
 	return nil
 }
@@ -202,6 +648,35 @@ func (sen5x *SEN5X) Reset() error {
 	return SEN5X_RESET.Write(sen5x.device, &sen5x.mu)
 }
 
+// reset resets the device, which forgets its volatile settings and VOC state, then writes them
+// again and restarts the measurements.
+func (sen5x *SEN5X) reset() error {
+	var state []byte
+	if sen5x.model().supports(&sensors.VOC) {
+		var err error
+		if state, err = sen5x.GetVOCState(); err != nil {
+			return fmt.Errorf("failed to read VOC state: %q", err)
+		}
+	}
+	if err := sen5x.Reset(); err != nil {
+		return err
+	}
+
+	configErr := sen5x.applyConfig()
+	if state != nil {
+		if err := sen5x.SetVOCState(state); err != nil {
+			log.ErrorLog.Printf("Failed to restore VOC state: %q", err)
+		}
+	}
+	if err := SEN5X_START_MEASUREMENT.Write(sen5x.device, &sen5x.mu); err != nil {
+		return fmt.Errorf("failed to restart measurements: %q", err)
+	}
+	if configErr != nil {
+		return fmt.Errorf("failed to configure device: %q", configErr)
+	}
+	return nil
+}
+
 func (sen5x *SEN5X) SerialNumber() error {
 	err := SEN5X_SERIAL_NUMBER.Write(sen5x.device, &sen5x.mu)
 	if err != nil {
@@ -247,7 +722,6 @@ func (sen5x *SEN5X) Versions() error {
 	return nil
 }
 
-// TODO: Properly implement this according to 5.4 of specs
 func (sen5x *SEN5X) Status() error {
 	err := SEN5X_READ_STATUS.Write(sen5x.device, &sen5x.mu)
 	if err != nil {
@@ -257,6 +731,6 @@ func (sen5x *SEN5X) Status() error {
 	if err != nil {
 		return fmt.Errorf("failed to read status: %q", err)
 	}
-	sen5x.deviceInfo.status = binary.BigEndian.Uint32(data)
+	sen5x.deviceInfo.status = SEN5XStatus(binary.BigEndian.Uint32(data))
 	return nil
 }
@@ -0,0 +1,105 @@
+package sensirion
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"periph.io/x/conn/v3/i2c"
+	"periph.io/x/conn/v3/physic"
+)
+
+// fakeSEN5XBus emulates the read/write settings of a SEN5x: the words written with a command are
+// read back by the same command, a reset clears them.
+type fakeSEN5XBus struct {
+	settings map[uint16][]byte // Words without their CRC, keyed by command
+	commands []uint16          // Commands written, in order
+	pending  uint16
+}
+
+func newFakeSEN5X(config SEN5XConfig) (*SEN5X, *fakeSEN5XBus) {
+	bus := &fakeSEN5XBus{settings: make(map[uint16][]byte)}
+	sen5x := &SEN5X{device: &i2c.Dev{Addr: 0x69, Bus: bus}, config: config}
+	sen5x.deviceInfo.productName = string(SEN55)
+	return sen5x, bus
+}
+
+func (f *fakeSEN5XBus) String() string {
+	return "fake sen5x"
+}
+
+func (f *fakeSEN5XBus) SetSpeed(physic.Frequency) error {
+	return nil
+}
+
+func (f *fakeSEN5XBus) Tx(addr uint16, w, r []byte) error {
+	if len(w) >= 2 {
+		code := binary.BigEndian.Uint16(w[0:2])
+		f.commands = append(f.commands, code)
+		f.pending = code
+		if code == SEN5X_RESET.code {
+			f.settings = make(map[uint16][]byte)
+		}
+		if len(w) > 2 {
+			if (len(w)-2)%3 != 0 || checkBufferCRC(w[2:]) != nil {
+				return fmt.Errorf("malformed write % x", w)
+			}
+			f.settings[code] = stripCRC(w[2:], uint8((len(w)-2)/3*2))
+		}
+	}
+	if len(r) > 0 {
+		words := make([]byte, len(r)/3*2)
+		copy(words, f.settings[f.pending])
+		for i := 0; i < len(words); i += 2 {
+			copy(r[i/2*3:], words[i:i+2])
+			r[i/2*3+2] = crc8(words[i : i+2])
+		}
+	}
+	return nil
+}
+
+// A reset through the maintenance API keeps the configured settings and the learned VOC state.
+func TestSEN5XResetRestoresSettings(t *testing.T) {
+	warmStart := uint16(0x8000)
+	interval := uint32(86400)
+	sen5x, bus := newFakeSEN5X(SEN5XConfig{
+		AutoCleaningInterval:    &interval,
+		VOCTuning:               &SEN5XTuning{100, 12, 12, 180, 50, 230},
+		TemperatureCompensation: &SEN5XTemperatureCompensation{Offset: -1.5},
+		WarmStart:               &warmStart,
+		RHTAccelerationMode:     SEN5X_RHT_ACCELERATION_MEDIUM,
+	})
+	state := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	bus.settings[SEN5X_RW_VOC_STATE.code] = state
+
+	if _, err := sen5x.Operations()["reset"].Run(nil); err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+	if last := bus.commands[len(bus.commands)-1]; last != SEN5X_START_MEASUREMENT.code {
+		t.Errorf("last command = 0x%04x, want the start of the measurements", last)
+	}
+
+	tests := []struct {
+		cmd  *Command
+		want []uint16
+	}{
+		{&SEN5X_RW_TEMP_OFFSET, SEN5XTemperatureCompensation{Offset: -1.5}.words()},
+		{&SEN5X_RW_WARM_START, []uint16{warmStart}},
+		{&SEN5X_RW_RHT_ACCEL_MODE, []uint16{2}},
+		{&SEN5X_RW_VOC_TUNING, sen5x.config.VOCTuning.words()},
+		{&SEN5X_RW_AUTO_CLEANING, []uint16{1, 20864}},
+	}
+	for _, test := range tests {
+		words, err := sen5x.readWords(test.cmd)
+		if err != nil {
+			t.Fatalf("reading %s failed: %v", test.cmd.description, err)
+		}
+		if !equalWords(words, test.want) {
+			t.Errorf("%s = %v after the reset, want %v", test.cmd.description, words, test.want)
+		}
+	}
+	if got := bus.settings[SEN5X_RW_VOC_STATE.code]; !bytes.Equal(got, state) {
+		t.Errorf("VOC state = % x after the reset, want % x", got, state)
+	}
+}
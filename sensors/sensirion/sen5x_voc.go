@@ -0,0 +1,230 @@
+package sensirion
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"azuremyst.org/go-home-sensors/log"
+	"azuremyst.org/go-home-sensors/sensors"
+)
+
+var (
+	SEN5X_RW_VOC_STATE  = Command{code: 0x6181, description: "Read/Write VOC algorithm state", delay: time.Duration(20 * time.Millisecond), size: 8}
+	SEN5X_RW_VOC_TUNING = Command{code: 0x60D0, description: "Read/Write VOC algorithm tuning parameters", delay: time.Duration(20 * time.Millisecond), size: 12}
+	SEN5X_RW_NOX_TUNING = Command{code: 0x60E1, description: "Read/Write NOx algorithm tuning parameters", delay: time.Duration(20 * time.Millisecond), size: 12}
+)
+
+const (
+	// Sensirion only recommends restoring the VOC state after short interruptions.
+	defaultVOCStateMaxAge       = 10 * time.Minute
+	defaultVOCStateSaveInterval = 5 * time.Minute
+)
+
+// SEN5XTuning holds the parameters of the VOC or NOx index algorithm, see the Sensirion
+// application note on the gas index algorithm for their meaning.
+type SEN5XTuning struct {
+	IndexOffset              int16 `toml:"index_offset"`
+	LearningTimeOffsetHours  int16 `toml:"learning_time_offset_hours"`
+	LearningTimeGainHours    int16 `toml:"learning_time_gain_hours"`
+	GatingMaxDurationMinutes int16 `toml:"gating_max_duration_minutes"`
+	StdInitial               int16 `toml:"std_initial"`
+	GainFactor               int16 `toml:"gain_factor"`
+}
+
+func (tuning SEN5XTuning) words() []uint16 {
+	return []uint16{uint16(tuning.IndexOffset), uint16(tuning.LearningTimeOffsetHours),
+		uint16(tuning.LearningTimeGainHours), uint16(tuning.GatingMaxDurationMinutes),
+		uint16(tuning.StdInitial), uint16(tuning.GainFactor)}
+}
+
+// sen5xVOCState is the content of the VOC state file.
+type sen5xVOCState struct {
+	SerialNumber string    `json:"serial_number"`
+	Saved        time.Time `json:"saved"`
+	State        []byte    `json:"state"`
+}
+
+func (sen5x *SEN5X) GetVOCTuning() (SEN5XTuning, error) {
+	return sen5x.getTuning(&SEN5X_RW_VOC_TUNING)
+}
+
+// SetVOCTuning only works while the measurements are stopped.
+func (sen5x *SEN5X) SetVOCTuning(tuning SEN5XTuning) error {
+	return SEN5X_RW_VOC_TUNING.WriteWords(sen5x.device, &sen5x.mu, tuning.words()...)
+}
+
+func (sen5x *SEN5X) GetNOxTuning() (SEN5XTuning, error) {
+	return sen5x.getTuning(&SEN5X_RW_NOX_TUNING)
+}
+
+// SetNOxTuning only works while the measurements are stopped.
+func (sen5x *SEN5X) SetNOxTuning(tuning SEN5XTuning) error {
+	return SEN5X_RW_NOX_TUNING.WriteWords(sen5x.device, &sen5x.mu, tuning.words()...)
+}
+
+func (sen5x *SEN5X) getTuning(cmd *Command) (SEN5XTuning, error) {
+	data, err := cmd.Read(sen5x.device, &sen5x.mu)
+	if err != nil {
+		return SEN5XTuning{}, err
+	}
+	word := func(i int) int16 {
+		return int16(binary.BigEndian.Uint16(data[2*i : 2*i+2]))
+	}
+	return SEN5XTuning{
+		IndexOffset:              word(0),
+		LearningTimeOffsetHours:  word(1),
+		LearningTimeGainHours:    word(2),
+		GatingMaxDurationMinutes: word(3),
+		StdInitial:               word(4),
+		GainFactor:               word(5),
+	}, nil
+}
+
+func (sen5x *SEN5X) GetVOCState() ([]byte, error) {
+	return SEN5X_RW_VOC_STATE.Read(sen5x.device, &sen5x.mu)
+}
+
+// SetVOCState only works while the measurements are stopped, the state is picked up when they start.
+func (sen5x *SEN5X) SetVOCState(state []byte) error {
+	if len(state) != int(SEN5X_RW_VOC_STATE.size) {
+		return fmt.Errorf("invalid VOC state of %d bytes", len(state))
+	}
+	words := make([]uint16, len(state)/2)
+	for i := range words {
+		words[i] = binary.BigEndian.Uint16(state[2*i : 2*i+2])
+	}
+	return SEN5X_RW_VOC_STATE.WriteWords(sen5x.device, &sen5x.mu, words...)
+}
+
+// applyTuning writes the configured tuning parameters which differ from the ones on the device.
+// The measurements have to be stopped.
+func (sen5x *SEN5X) applyTuning() error {
+	tunings := []struct {
+		name string
+		want *SEN5XTuning
+		get  func() (SEN5XTuning, error)
+		set  func(SEN5XTuning) error
+	}{
+		{"VOC", sen5x.config.VOCTuning, sen5x.GetVOCTuning, sen5x.SetVOCTuning},
+		{"NOx", sen5x.config.NOxTuning, sen5x.GetNOxTuning, sen5x.SetNOxTuning},
+	}
+	for _, tuning := range tunings {
+		if tuning.want == nil {
+			continue
+		}
+		current, err := tuning.get()
+		if err != nil {
+			return fmt.Errorf("failed to read %s tuning: %q", tuning.name, err)
+		}
+		if current == *tuning.want {
+			continue
+		}
+		if err := tuning.set(*tuning.want); err != nil {
+			return fmt.Errorf("failed to write %s tuning: %q", tuning.name, err)
+		}
+		if current, err = tuning.get(); err != nil {
+			return fmt.Errorf("failed to read %s tuning: %q", tuning.name, err)
+		}
+		if current != *tuning.want {
+			return fmt.Errorf("%s tuning is %+v after setting %+v", tuning.name, current, *tuning.want)
+		}
+	}
+	return nil
+}
+
+// restoreVOCState writes the saved VOC state back to the device when it belongs to it and is
+// recent enough. The measurements have to be stopped.
+func (sen5x *SEN5X) restoreVOCState() error {
+	if sen5x.config.VOCStateFile == "" || !sen5x.model().supports(&sensors.VOC) {
+		return nil
+	}
+	content, err := os.ReadFile(sen5x.config.VOCStateFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var saved sen5xVOCState
+	if err := json.Unmarshal(content, &saved); err != nil {
+		return fmt.Errorf("invalid VOC state file %s: %v", sen5x.config.VOCStateFile, err)
+	}
+	if saved.SerialNumber != sen5x.deviceInfo.serialNumber {
+		log.InfoLog.Printf("Ignoring VOC state of %s, the sensor is %s", saved.SerialNumber, sen5x.deviceInfo.serialNumber)
+		return nil
+	}
+	if age := time.Since(saved.Saved); age > sen5x.vocStateMaxAge() {
+		log.InfoLog.Printf("Ignoring VOC state saved %s ago, the VOC index learns from scratch", age.Round(time.Second))
+		return nil
+	}
+	if err := sen5x.SetVOCState(saved.State); err != nil {
+		return err
+	}
+	log.InfoLog.Printf("Restored VOC state saved at %s", saved.Saved.Format(time.RFC3339))
+	return nil
+}
+
+// saveVOCState stores the current VOC state of the device, which is readable while measuring.
+func (sen5x *SEN5X) saveVOCState() error {
+	sen5x.stateMu.Lock()
+	defer sen5x.stateMu.Unlock()
+
+	state, err := sen5x.GetVOCState()
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(sen5xVOCState{
+		SerialNumber: sen5x.deviceInfo.serialNumber,
+		Saved:        time.Now(),
+		State:        state,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Write and rename so a crash never leaves a truncated state behind.
+	tmp := sen5x.config.VOCStateFile + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, sen5x.config.VOCStateFile); err != nil {
+		return err
+	}
+	sen5x.stateSaved = time.Now()
+	return nil
+}
+
+// saveVOCStatePeriodically saves the state once the save interval elapsed, so a crash loses little.
+func (sen5x *SEN5X) saveVOCStatePeriodically() {
+	if sen5x.config.VOCStateFile == "" || !sen5x.model().supports(&sensors.VOC) {
+		return
+	}
+	interval := sen5x.config.VOCStateSaveInterval
+	if interval <= 0 {
+		interval = defaultVOCStateSaveInterval
+	}
+	if time.Since(sen5x.stateSaved) < interval {
+		return
+	}
+	if err := sen5x.saveVOCState(); err != nil {
+		log.ErrorLog.Printf("Failed to save VOC state: %q", err)
+	}
+}
+
+func (sen5x *SEN5X) vocStateMaxAge() time.Duration {
+	if sen5x.config.VOCStateMaxAge > 0 {
+		return sen5x.config.VOCStateMaxAge
+	}
+	return defaultVOCStateMaxAge
+}
+
+// Close saves the VOC state on shutdown.
+func (sen5x *SEN5X) Close() error {
+	if sen5x.device == nil || sen5x.config.VOCStateFile == "" || !sen5x.model().supports(&sensors.VOC) {
+		return nil
+	}
+	return sen5x.saveVOCState()
+}
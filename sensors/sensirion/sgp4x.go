@@ -0,0 +1,365 @@
+package sensirion
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"azuremyst.org/go-home-sensors/log"
+	"azuremyst.org/go-home-sensors/sensors"
+	"periph.io/x/conn/v3/i2c"
+)
+
+var (
+	SGP40_MEASURE_RAW_SIGNAL  = Command{code: 0x260F, description: "Measure raw signal", delay: time.Duration(30 * time.Millisecond), size: 2}
+	SGP41_CONDITIONING        = Command{code: 0x2612, description: "Conditioning", delay: time.Duration(50 * time.Millisecond), size: 2}
+	SGP41_MEASURE_RAW_SIGNALS = Command{code: 0x2619, description: "Measure raw signals", delay: time.Duration(50 * time.Millisecond), size: 4}
+	SGP4X_SELFTEST            = Command{code: 0x280E, description: "Self test", delay: time.Duration(320 * time.Millisecond), size: 2}
+	SGP4X_HEATER_OFF          = Command{code: 0x3615, description: "Turn heater off", delay: time.Duration(1 * time.Millisecond), size: 0}
+	SGP4X_SERIALNUMBER        = Command{code: 0x3682, description: "Serial number", delay: time.Duration(1 * time.Millisecond), size: 6}
+)
+
+const (
+	// The gas index algorithm expects a sample every second, independently of the collection
+	// frequency, so the sensor is sampled in the background.
+	sgp4xSamplingInterval = time.Second
+	// The SGP41 NOx pixel needs 10 seconds of conditioning after power on, longer damages it.
+	sgp41ConditioningSamples = 10
+	// Compensation words of 50% RH and 25°C, used without a compensation source.
+	sgp4xDefaultHumidity    = 0x8000
+	sgp4xDefaultTemperature = 0x6666
+)
+
+// SGP4XConfig holds the options of the [sensors.sgp40] and [sensors.sgp41] sections.
+type SGP4XConfig struct {
+	// Sensor whose temperature and humidity readings compensate the raw signals, the defaults of
+	// 25°C and 50% RH are used while its readings are missing.
+	CompensationSource string `toml:"compensation_source"`
+	// Gas index algorithm parameters, the ones left out keep the default of the algorithm.
+	VOCTuning *SEN5XTuning `toml:"voc_tuning"`
+	NOxTuning *SEN5XTuning `toml:"nox_tuning"` // SGP41 only
+	// Also report the raw signal ticks.
+	RawSignals bool `toml:"raw_signals"`
+}
+
+// sgp4x samples the raw signals of the SGP40 and SGP41 and runs the gas index algorithm on them,
+// giving VOC and NOx indices comparable with the ones of the SEN5x.
+type sgp4x struct {
+	device *i2c.Dev
+	mu     sync.Mutex
+
+	config       SGP4XConfig
+	hasNOx       bool
+	serialNumber string
+
+	dataMu       sync.Mutex // Guards the fields below, shared with the sampling goroutine
+	humidity     uint16
+	temperature  uint16
+	compensated  bool
+	vocAlgorithm *gasIndexAlgorithm
+	noxAlgorithm *gasIndexAlgorithm
+	vocIndex     int32
+	noxIndex     int32
+	rawVOC       uint16
+	rawNOx       uint16
+	failing      bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func (sgp *sgp4x) Configure(decode func(v interface{}) error) error {
+	if err := decode(&sgp.config); err != nil {
+		return err
+	}
+	for name, tuning := range map[string]*SEN5XTuning{"voc_tuning": sgp.config.VOCTuning, "nox_tuning": sgp.config.NOxTuning} {
+		if tuning == nil {
+			continue
+		}
+		if err := validateTuning(*tuning); err != nil {
+			return fmt.Errorf("invalid %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// validateTuning checks the set parameters against the ranges the SEN5x accepts on the device.
+func validateTuning(tuning SEN5XTuning) error {
+	ranges := []struct {
+		name     string
+		value    int16
+		min, max int16
+	}{
+		{"index_offset", tuning.IndexOffset, 1, 250},
+		{"learning_time_offset_hours", tuning.LearningTimeOffsetHours, 1, 1000},
+		{"learning_time_gain_hours", tuning.LearningTimeGainHours, 1, 1000},
+		{"gating_max_duration_minutes", tuning.GatingMaxDurationMinutes, 0, 3000},
+		{"std_initial", tuning.StdInitial, 10, 5000},
+		{"gain_factor", tuning.GainFactor, 1, 1000},
+	}
+	for _, r := range ranges {
+		if r.value != 0 && (r.value < r.min || r.value > r.max) {
+			return fmt.Errorf("%s %d out of range %d to %d", r.name, r.value, r.min, r.max)
+		}
+	}
+	return nil
+}
+
+func (sgp *sgp4x) initialize(name string, bus i2c.Bus, addr uint16) {
+	sgp.device = &i2c.Dev{Addr: addr, Bus: bus}
+	sgp.humidity, sgp.temperature = sgp4xDefaultHumidity, sgp4xDefaultTemperature
+
+	interval := sgp4xSamplingInterval.Seconds()
+	sgp.vocAlgorithm = newGasIndexAlgorithm(gasIndexVOC, interval)
+	if sgp.config.VOCTuning != nil {
+		sgp.vocAlgorithm.setTuning(*sgp.config.VOCTuning)
+	}
+	if sgp.hasNOx {
+		sgp.noxAlgorithm = newGasIndexAlgorithm(gasIndexNOx, interval)
+		if sgp.config.NOxTuning != nil {
+			sgp.noxAlgorithm.setTuning(*sgp.config.NOxTuning)
+		}
+	}
+
+	// The serial number is informational, the sampling reports its own failures.
+	if response, err := SGP4X_SERIALNUMBER.Read(sgp.device, &sgp.mu); err != nil {
+		log.ErrorLog.Printf("Failed to read SN: %q", err)
+	} else {
+		sgp.serialNumber = fmt.Sprintf("%X", response)
+	}
+	log.InfoLog.Printf("Sensirion %s\n\tSerialNumber: %s\n\tCompensationSource: %s", strings.ToUpper(name), sgp.serialNumber, sgp.config.CompensationSource)
+
+	sgp.stop = make(chan struct{})
+	sgp.done = make(chan struct{})
+	go sgp.sample()
+}
+
+// sample feeds the gas index algorithms every sampling interval until Close.
+func (sgp *sgp4x) sample() {
+	defer close(sgp.done)
+	ticker := time.NewTicker(sgp4xSamplingInterval)
+	defer ticker.Stop()
+
+	conditioning := 0
+	if sgp.hasNOx {
+		conditioning = sgp41ConditioningSamples
+	}
+	for {
+		select {
+		case <-sgp.stop:
+			return
+		case <-ticker.C:
+		}
+
+		err := sgp.measure(conditioning > 0)
+		if conditioning > 0 {
+			conditioning--
+		}
+		sgp.dataMu.Lock()
+		if err != nil && !sgp.failing {
+			log.ErrorLog.Printf("Failed to measure raw signals: %q", err)
+		} else if err == nil && sgp.failing {
+			log.InfoLog.Printf("Raw signals measured again")
+		}
+		sgp.failing = err != nil
+		sgp.dataMu.Unlock()
+	}
+}
+
+func (sgp *sgp4x) measure(conditioning bool) error {
+	sgp.dataMu.Lock()
+	humidity, temperature := sgp.humidity, sgp.temperature
+	sgp.dataMu.Unlock()
+
+	command := SGP40_MEASURE_RAW_SIGNAL
+	if conditioning {
+		command = SGP41_CONDITIONING
+	} else if sgp.hasNOx {
+		command = SGP41_MEASURE_RAW_SIGNALS
+	}
+	response, err := command.ReadWords(sgp.device, &sgp.mu, humidity, temperature)
+	if err != nil || conditioning {
+		// The VOC signal of the conditioning is not fed to the algorithm, like Sensirion does.
+		return err
+	}
+
+	sgp.dataMu.Lock()
+	defer sgp.dataMu.Unlock()
+	sgp.rawVOC = binary.BigEndian.Uint16(response[0:2])
+	sgp.vocIndex = sgp.vocAlgorithm.process(int32(sgp.rawVOC))
+	if len(response) == 4 {
+		sgp.rawNOx = binary.BigEndian.Uint16(response[2:4])
+		sgp.noxIndex = sgp.noxAlgorithm.process(int32(sgp.rawNOx))
+	}
+	return nil
+}
+
+func (sgp *sgp4x) collect(name string) []sensors.MeasurementRecording {
+	sgp.dataMu.Lock()
+	defer sgp.dataMu.Unlock()
+	if sgp.failing {
+		return nil
+	}
+
+	measurements := make([]sensors.MeasurementRecording, 0)
+	// The indices are 0 until the algorithms are past their initial blackout.
+	if sgp.vocIndex > 0 {
+		measurements = append(measurements, sensors.MeasurementRecording{
+			Measure: &sensors.VOC,
+			Value:   float64(sgp.vocIndex),
+			Sensor:  name,
+		})
+	}
+	if sgp.hasNOx && sgp.noxIndex > 0 {
+		measurements = append(measurements, sensors.MeasurementRecording{
+			Measure: &sensors.NOx,
+			Value:   float64(sgp.noxIndex),
+			Sensor:  name,
+		})
+	}
+	if sgp.config.RawSignals && sgp.rawVOC > 0 {
+		measurements = append(measurements, sensors.MeasurementRecording{
+			Measure:  &sensors.GasRawSignal,
+			Value:    float64(sgp.rawVOC),
+			Sensor:   name,
+			Metadata: map[sensors.Metadata]string{sensors.Gas: "voc"},
+		})
+		if sgp.hasNOx && sgp.rawNOx > 0 {
+			measurements = append(measurements, sensors.MeasurementRecording{
+				Measure:  &sensors.GasRawSignal,
+				Value:    float64(sgp.rawNOx),
+				Sensor:   name,
+				Metadata: map[sensors.Metadata]string{sensors.Gas: "nox"},
+			})
+		}
+	}
+	return measurements
+}
+
+// Observe feeds the latest temperature and humidity of the configured source into the
+// compensation of the raw signals. Without them the defaults are used again.
+func (sgp *sgp4x) Observe(recordings []sensors.MeasurementRecording) {
+	if sgp.config.CompensationSource == "" {
+		return
+	}
+
+	humidity, temperature := math.NaN(), math.NaN()
+	for _, recording := range recordings {
+		if !strings.EqualFold(recording.Sensor, sgp.config.CompensationSource) {
+			continue
+		}
+		switch recording.Measure.ID {
+		case sensors.Humidity.ID:
+			humidity = recording.Value
+		case sensors.Temperature.ID:
+			temperature = recording.Value
+		}
+	}
+
+	sgp.dataMu.Lock()
+	defer sgp.dataMu.Unlock()
+	if math.IsNaN(humidity) || math.IsNaN(temperature) {
+		if sgp.compensated {
+			log.InfoLog.Printf("No temperature and humidity from %s, falling back to 25C and 50%%", sgp.config.CompensationSource)
+			sgp.humidity, sgp.temperature = sgp4xDefaultHumidity, sgp4xDefaultTemperature
+			sgp.compensated = false
+		}
+		return
+	}
+	sgp.humidity = uint16(math.Round(math.Min(math.Max(humidity, 0), 100) * 65535 / 100))
+	sgp.temperature = uint16(math.Round((math.Min(math.Max(temperature, -45), 130) + 45) * 65535 / 175))
+	sgp.compensated = true
+}
+
+func (sgp *sgp4x) Operations() map[string]sensors.Operation {
+	return map[string]sensors.Operation{
+		"info": {
+			Description: "Serial number and compensation",
+			Run: func(args map[string]string) (string, error) {
+				sgp.dataMu.Lock()
+				defer sgp.dataMu.Unlock()
+				return fmt.Sprintf("SerialNumber: %s\nCompensationSource: %s\nCompensated: %t",
+					sgp.serialNumber, sgp.config.CompensationSource, sgp.compensated), nil
+			},
+		},
+		"selftest": {
+			Description: "Self test of the hotplates",
+			Run: func(args map[string]string) (string, error) {
+				return "Self test passed", sgp.Test()
+			},
+		},
+	}
+}
+
+func (sgp *sgp4x) Test() error {
+	response, err := SGP4X_SELFTEST.Read(sgp.device, &sgp.mu)
+	if err != nil {
+		return err
+	}
+	result := binary.BigEndian.Uint16(response)
+	// The SGP40 answers 0xD400 when passing, the SGP41 reports the failing pixels in bits 0-1.
+	if (!sgp.hasNOx && result != 0xD400) || (sgp.hasNOx && result&0x03 != 0) {
+		return fmt.Errorf("self test failed with 0x%04X", result)
+	}
+	return nil
+}
+
+// Close stops the sampling and turns the hotplate off.
+func (sgp *sgp4x) Close() error {
+	if sgp.stop == nil {
+		return nil
+	}
+	close(sgp.stop)
+	<-sgp.done
+	sgp.stop = nil
+	return SGP4X_HEATER_OFF.Write(sgp.device, &sgp.mu)
+}
+
+type SGP40 struct {
+	sgp4x
+}
+
+type SGP41 struct {
+	sgp4x
+}
+
+func init() {
+	sensors.RegisterSensor(&SGP40{})
+	sensors.RegisterSensor(&SGP41{})
+}
+
+func (sgp40 *SGP40) Initialize(bus i2c.Bus, addr uint16) {
+	sgp40.initialize(sgp40.Name(), bus, addr)
+}
+
+func (sgp40 *SGP40) Name() string {
+	return "sgp40"
+}
+
+func (sgp40 *SGP40) Family(name string) bool {
+	return strings.EqualFold(sgp40.Name(), name)
+}
+
+func (sgp40 *SGP40) Collect() []sensors.MeasurementRecording {
+	return sgp40.collect(sgp40.Name())
+}
+
+func (sgp41 *SGP41) Initialize(bus i2c.Bus, addr uint16) {
+	sgp41.hasNOx = true
+	sgp41.initialize(sgp41.Name(), bus, addr)
+}
+
+func (sgp41 *SGP41) Name() string {
+	return "sgp41"
+}
+
+func (sgp41 *SGP41) Family(name string) bool {
+	return strings.EqualFold(sgp41.Name(), name)
+}
+
+func (sgp41 *SGP41) Collect() []sensors.MeasurementRecording {
+	return sgp41.collect(sgp41.Name())
+}
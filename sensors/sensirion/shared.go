@@ -14,6 +14,17 @@ type Command struct {
 	description string
 	delay       time.Duration
 	size        uint8
+	singleByte  bool // The SHT4x only takes the low byte of the code
+}
+
+// encode returns the command as sent on the bus.
+func (cmd *Command) encode() []byte {
+	if cmd.singleByte {
+		return []byte{byte(cmd.code)}
+	}
+	encodedCommand := make([]byte, 2)
+	binary.BigEndian.PutUint16(encodedCommand, cmd.code)
+	return encodedCommand
 }
 
 func crc8(buffer []byte) byte {
@@ -47,9 +58,7 @@ func (cmd *Command) Write(device *i2c.Dev, mu *sync.Mutex) error {
 	mu.Lock()
 	defer mu.Unlock()
 
-	encodedCommand := make([]byte, 2)
-	binary.BigEndian.PutUint16(encodedCommand, cmd.code)
-	if _, err := device.Write(encodedCommand); err != nil {
+	if _, err := device.Write(cmd.encode()); err != nil {
 		return fmt.Errorf("error while running %s: %q", cmd.description, err)
 	}
 
@@ -65,10 +74,8 @@ func (cmd *Command) Read(device *i2c.Dev, mu *sync.Mutex) ([]byte, error) {
 
 	actualSize := (cmd.size / 2) * 3
 
-	c := make([]byte, 2)
 	r := make([]byte, actualSize)
-	binary.BigEndian.PutUint16(c, cmd.code)
-	if err := device.Tx(c, nil); err != nil {
+	if err := device.Tx(cmd.encode(), nil); err != nil {
 		return nil, fmt.Errorf("error while sending request %s: %q", cmd.description, err)
 	}
 
@@ -84,7 +91,12 @@ func (cmd *Command) Read(device *i2c.Dev, mu *sync.Mutex) ([]byte, error) {
 		return nil, err
 	}
 
-	response := make([]byte, cmd.size)
+	return stripCRC(r, cmd.size), nil
+}
+
+// stripCRC drops the CRC byte following each word of a response.
+func stripCRC(r []byte, size uint8) []byte {
+	response := make([]byte, size)
 	idx := 0
 	for i, b := range r {
 		if (i+1)%3 != 0 {
@@ -92,8 +104,7 @@ func (cmd *Command) Read(device *i2c.Dev, mu *sync.Mutex) ([]byte, error) {
 			idx++
 		}
 	}
-
-	return response, nil
+	return response
 }
 
 func (cmd *Command) WriteUint16(device *i2c.Dev, mu *sync.Mutex, value uint16) error {
@@ -137,27 +148,81 @@ func (cmd *Command) WriteUint32(device *i2c.Dev, mu *sync.Mutex, value uint32) e
 	return nil
 }
 
+// WriteWords writes the command followed by the words, each with its CRC.
+func (cmd *Command) WriteWords(device *i2c.Dev, mu *sync.Mutex, words ...uint16) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	encodedCommand := make([]byte, 2+3*len(words))
+	binary.BigEndian.PutUint16(encodedCommand, cmd.code)
+	idx := 2
+	for _, word := range words {
+		idx = valueBigEndianEncode(word, encodedCommand, idx)
+	}
+	if _, err := device.Write(encodedCommand); err != nil {
+		return fmt.Errorf("error while running %s: %q", cmd.description, err)
+	}
+
+	if cmd.delay > 0 {
+		time.Sleep(cmd.delay)
+	}
+	return nil
+}
+
 func (cmd *Command) ReadUint16(device *i2c.Dev, mu *sync.Mutex, value uint16) ([]byte, error) {
 	mu.Lock()
 	defer mu.Unlock()
 
 	c := make([]byte, 5)
-	r := make([]byte, cmd.size)
+	r := make([]byte, (cmd.size/2)*3)
 	binary.BigEndian.PutUint16(c, cmd.code)
 	c[2] = byte((value >> 8) & 0xFF)
 	c[3] = byte(value & 0xFF)
 	c[4] = crc8(c[2:4])
-	if err := device.Tx(c, r); err != nil {
+	if err := device.Tx(c, nil); err != nil {
 		return nil, fmt.Errorf("error while %s: %q", cmd.description, err)
 	}
+
+	if cmd.delay > 0 {
+		time.Sleep(cmd.delay)
+	}
+
+	if err := device.Tx(nil, r); err != nil {
+		return nil, fmt.Errorf("error while reading %s: %q", cmd.description, err)
+	}
 	if err := checkBufferCRC(r); err != nil {
 		return nil, err
 	}
+	return stripCRC(r, cmd.size), nil
+}
+
+// ReadWords sends the command followed by the words, each with its CRC, and reads the response.
+func (cmd *Command) ReadWords(device *i2c.Dev, mu *sync.Mutex, words ...uint16) ([]byte, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	c := make([]byte, 2+3*len(words))
+	binary.BigEndian.PutUint16(c, cmd.code)
+	idx := 2
+	for _, word := range words {
+		idx = valueBigEndianEncode(word, c, idx)
+	}
+	r := make([]byte, (cmd.size/2)*3)
+	if err := device.Tx(c, nil); err != nil {
+		return nil, fmt.Errorf("error while sending request %s: %q", cmd.description, err)
+	}
 
 	if cmd.delay > 0 {
 		time.Sleep(cmd.delay)
 	}
-	return r, nil
+
+	if err := device.Tx(nil, r); err != nil {
+		return nil, fmt.Errorf("error while reading request %s: %q", cmd.description, err)
+	}
+	if err := checkBufferCRC(r); err != nil {
+		return nil, err
+	}
+	return stripCRC(r, cmd.size), nil
 }
 
 func bytesToString(data []byte) string {
@@ -176,3 +241,8 @@ func valueBigEndianEncode(value uint16, array []byte, stardIdx int) int {
 	array[stardIdx+2] = crc8(array[stardIdx : stardIdx+2])
 	return stardIdx + 3
 }
+
+// shtTemperature converts the raw temperature word of the SHT3x and SHT4x to degrees Celsius.
+func shtTemperature(raw uint16) float64 {
+	return -45 + 175*float64(raw)/65535
+}
@@ -0,0 +1,195 @@
+package sensirion
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"azuremyst.org/go-home-sensors/log"
+	"azuremyst.org/go-home-sensors/sensors"
+	"periph.io/x/conn/v3/i2c"
+)
+
+// Single shot measurements without clock stretching, the sensor NACKs reads until done.
+var (
+	SHT3X_MEASURE_HIGH   = Command{code: 0x2400, description: "Measure high repeatability", delay: time.Duration(16 * time.Millisecond), size: 4}
+	SHT3X_MEASURE_MEDIUM = Command{code: 0x240B, description: "Measure medium repeatability", delay: time.Duration(7 * time.Millisecond), size: 4}
+	SHT3X_MEASURE_LOW    = Command{code: 0x2416, description: "Measure low repeatability", delay: time.Duration(5 * time.Millisecond), size: 4}
+	SHT3X_SERIALNUMBER   = Command{code: 0x3780, description: "Serial number", delay: time.Duration(1 * time.Millisecond), size: 4}
+	SHT3X_HEATER_ENABLE  = Command{code: 0x306D, description: "Enable heater", delay: time.Duration(1 * time.Millisecond), size: 0}
+	SHT3X_HEATER_DISABLE = Command{code: 0x3066, description: "Disable heater", delay: time.Duration(1 * time.Millisecond), size: 0}
+	SHT3X_READ_STATUS    = Command{code: 0xF32D, description: "Read status", delay: time.Duration(1 * time.Millisecond), size: 2}
+	SHT3X_CLEAR_STATUS   = Command{code: 0x3041, description: "Clear status", delay: time.Duration(1 * time.Millisecond), size: 0}
+	SHT3X_SOFT_RESET     = Command{code: 0x30A2, description: "Soft reset", delay: time.Duration(2 * time.Millisecond), size: 0}
+)
+
+const (
+	SHT_PRECISION_HIGH   = "high"
+	SHT_PRECISION_MEDIUM = "medium"
+	SHT_PRECISION_LOW    = "low"
+)
+
+// Heater bit of the status register
+const sht3xStatusHeater = 1 << 13
+
+// SHT3XConfig holds the options of the [sensors.sht3x] section.
+type SHT3XConfig struct {
+	// Repeatability of the measurements: high (default), medium or low. Lower ones are faster and
+	// use less power, but are noisier.
+	Precision string
+}
+
+type SHT3XMeasurement struct {
+	Humidity    float64
+	Temperature float64
+}
+
+type SHT3X struct {
+	device *i2c.Dev
+	mu     sync.Mutex
+
+	config       SHT3XConfig
+	serialNumber string
+	data         SHT3XMeasurement
+}
+
+func init() {
+	sensors.RegisterSensor(&SHT3X{})
+}
+
+func (sht3x *SHT3X) Configure(decode func(v interface{}) error) error {
+	if err := decode(&sht3x.config); err != nil {
+		return err
+	}
+	var err error
+	sht3x.config.Precision, err = validPrecision(sht3x.config.Precision)
+	return err
+}
+
+func (sht3x *SHT3X) Initialize(bus i2c.Bus, addr uint16) {
+	sht3x.device = &i2c.Dev{Addr: addr, Bus: bus}
+	if err := SHT3X_SOFT_RESET.Write(sht3x.device, &sht3x.mu); err != nil {
+		log.ErrorLog.Printf("Failed to reset device: %q", err)
+		return
+	}
+	if err := SHT3X_CLEAR_STATUS.Write(sht3x.device, &sht3x.mu); err != nil {
+		log.ErrorLog.Printf("Failed to clear status: %q", err)
+	}
+	if err := sht3x.SerialNumber(); err != nil {
+		log.ErrorLog.Printf("Failed to read SN: %q", err)
+	}
+
+	log.InfoLog.Printf("Sensirion SHT3x\n\tSerialNumber: %s\n\tPrecision: %s", sht3x.serialNumber, sht3x.config.Precision)
+}
+
+func (sht3x *SHT3X) Name() string {
+	return "sht3x"
+}
+
+func (sht3x *SHT3X) Family(name string) bool {
+	return len(name) == 5 && strings.HasPrefix(strings.ToLower(name), "sht3")
+}
+
+func (sht3x *SHT3X) Collect() []sensors.MeasurementRecording {
+	if err := sht3x.readData(); err != nil {
+		log.ErrorLog.Printf("Failed to measure: %q", err)
+		return nil
+	}
+
+	measurements := make([]sensors.MeasurementRecording, 0)
+	measurements = append(measurements, sensors.MeasurementRecording{
+		Measure: &sensors.Temperature,
+		Value:   sht3x.data.Temperature,
+		Sensor:  sht3x.Name(),
+	})
+	measurements = append(measurements, sensors.MeasurementRecording{
+		Measure: &sensors.Humidity,
+		Value:   sht3x.data.Humidity,
+		Sensor:  sht3x.Name(),
+	})
+	return measurements
+}
+
+func (sht3x *SHT3X) Operations() map[string]sensors.Operation {
+	return map[string]sensors.Operation{
+		"info": {
+			Description: "Serial number and status",
+			Run: func(args map[string]string) (string, error) {
+				status, err := sht3x.Status()
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("SerialNumber: %s\nPrecision: %s\nHeater: %t\nStatus: 0x%04X",
+					sht3x.serialNumber, sht3x.config.Precision, status&sht3xStatusHeater != 0, status), nil
+			},
+		},
+		"heater": {
+			Description: "Switch the heater on or off, e.g. to evaporate condensation. Readings are off while it is on",
+			Args:        []string{"enabled"},
+			Run: func(args map[string]string) (string, error) {
+				enable, err := strconv.ParseBool(args["enabled"])
+				if err != nil {
+					return "", fmt.Errorf("invalid enabled %q: %v", args["enabled"], err)
+				}
+				return fmt.Sprintf("Heater: %t", enable), sht3x.ToggleHeater(enable)
+			},
+		},
+	}
+}
+
+func (sht3x *SHT3X) readData() error {
+	command := SHT3X_MEASURE_HIGH
+	switch sht3x.config.Precision {
+	case SHT_PRECISION_MEDIUM:
+		command = SHT3X_MEASURE_MEDIUM
+	case SHT_PRECISION_LOW:
+		command = SHT3X_MEASURE_LOW
+	}
+	response, err := command.Read(sht3x.device, &sht3x.mu)
+	if err != nil {
+		return err
+	}
+
+	sht3x.data.Temperature = shtTemperature(binary.BigEndian.Uint16(response[0:2]))
+	sht3x.data.Humidity = 100 * float64(binary.BigEndian.Uint16(response[2:4])) / 65535
+	return nil
+}
+
+func (sht3x *SHT3X) SerialNumber() error {
+	response, err := SHT3X_SERIALNUMBER.Read(sht3x.device, &sht3x.mu)
+	if err != nil {
+		return err
+	}
+
+	sht3x.serialNumber = fmt.Sprintf("%X", response)
+	return nil
+}
+
+func (sht3x *SHT3X) Status() (uint16, error) {
+	response, err := SHT3X_READ_STATUS.Read(sht3x.device, &sht3x.mu)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(response), nil
+}
+
+func (sht3x *SHT3X) ToggleHeater(enable bool) error {
+	if enable {
+		return SHT3X_HEATER_ENABLE.Write(sht3x.device, &sht3x.mu)
+	}
+	return SHT3X_HEATER_DISABLE.Write(sht3x.device, &sht3x.mu)
+}
+
+// validPrecision checks the precision of the SHT3x and SHT4x, defaulting to high.
+func validPrecision(precision string) (string, error) {
+	switch precision {
+	case "":
+		return SHT_PRECISION_HIGH, nil
+	case SHT_PRECISION_HIGH, SHT_PRECISION_MEDIUM, SHT_PRECISION_LOW:
+		return precision, nil
+	}
+	return "", fmt.Errorf("unknown precision %q", precision)
+}
@@ -0,0 +1,180 @@
+package sensirion
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"azuremyst.org/go-home-sensors/log"
+	"azuremyst.org/go-home-sensors/sensors"
+	"periph.io/x/conn/v3/i2c"
+)
+
+// The SHT4x commands are a single byte. The heater commands measure once the heater is off again.
+var (
+	SHT4X_MEASURE_HIGH       = Command{code: 0xFD, description: "Measure high precision", delay: time.Duration(10 * time.Millisecond), size: 4, singleByte: true}
+	SHT4X_MEASURE_MEDIUM     = Command{code: 0xF6, description: "Measure medium precision", delay: time.Duration(5 * time.Millisecond), size: 4, singleByte: true}
+	SHT4X_MEASURE_LOW        = Command{code: 0xE0, description: "Measure low precision", delay: time.Duration(2 * time.Millisecond), size: 4, singleByte: true}
+	SHT4X_SERIALNUMBER       = Command{code: 0x89, description: "Serial number", delay: time.Duration(10 * time.Millisecond), size: 4, singleByte: true}
+	SHT4X_SOFT_RESET         = Command{code: 0x94, description: "Soft reset", delay: time.Duration(1 * time.Millisecond), size: 0, singleByte: true}
+	SHT4X_HEATER_200MW_1S    = Command{code: 0x39, description: "Heater 200mW 1s", delay: time.Duration(1100 * time.Millisecond), size: 4, singleByte: true}
+	SHT4X_HEATER_200MW_100MS = Command{code: 0x32, description: "Heater 200mW 0.1s", delay: time.Duration(110 * time.Millisecond), size: 4, singleByte: true}
+	SHT4X_HEATER_110MW_1S    = Command{code: 0x2F, description: "Heater 110mW 1s", delay: time.Duration(1100 * time.Millisecond), size: 4, singleByte: true}
+	SHT4X_HEATER_110MW_100MS = Command{code: 0x24, description: "Heater 110mW 0.1s", delay: time.Duration(110 * time.Millisecond), size: 4, singleByte: true}
+	SHT4X_HEATER_20MW_1S     = Command{code: 0x1E, description: "Heater 20mW 1s", delay: time.Duration(1100 * time.Millisecond), size: 4, singleByte: true}
+	SHT4X_HEATER_20MW_100MS  = Command{code: 0x15, description: "Heater 20mW 0.1s", delay: time.Duration(110 * time.Millisecond), size: 4, singleByte: true}
+)
+
+// sht4xHeaters maps the power in mW and the duration to the heater commands.
+var sht4xHeaters = map[string]map[string]Command{
+	"200": {"1s": SHT4X_HEATER_200MW_1S, "100ms": SHT4X_HEATER_200MW_100MS},
+	"110": {"1s": SHT4X_HEATER_110MW_1S, "100ms": SHT4X_HEATER_110MW_100MS},
+	"20":  {"1s": SHT4X_HEATER_20MW_1S, "100ms": SHT4X_HEATER_20MW_100MS},
+}
+
+// SHT4XConfig holds the options of the [sensors.sht4x] section.
+type SHT4XConfig struct {
+	// Precision of the measurements: high (default), medium or low. Lower ones are faster and use
+	// less power, but are noisier.
+	Precision string
+}
+
+type SHT4XMeasurement struct {
+	Humidity    float64
+	Temperature float64
+}
+
+type SHT4X struct {
+	device *i2c.Dev
+	mu     sync.Mutex
+
+	config       SHT4XConfig
+	serialNumber string
+	data         SHT4XMeasurement
+}
+
+func init() {
+	sensors.RegisterSensor(&SHT4X{})
+}
+
+func (sht4x *SHT4X) Configure(decode func(v interface{}) error) error {
+	if err := decode(&sht4x.config); err != nil {
+		return err
+	}
+	var err error
+	sht4x.config.Precision, err = validPrecision(sht4x.config.Precision)
+	return err
+}
+
+func (sht4x *SHT4X) Initialize(bus i2c.Bus, addr uint16) {
+	sht4x.device = &i2c.Dev{Addr: addr, Bus: bus}
+	if err := SHT4X_SOFT_RESET.Write(sht4x.device, &sht4x.mu); err != nil {
+		log.ErrorLog.Printf("Failed to reset device: %q", err)
+		return
+	}
+	if err := sht4x.SerialNumber(); err != nil {
+		log.ErrorLog.Printf("Failed to read SN: %q", err)
+	}
+
+	log.InfoLog.Printf("Sensirion SHT4x\n\tSerialNumber: %s\n\tPrecision: %s", sht4x.serialNumber, sht4x.config.Precision)
+}
+
+func (sht4x *SHT4X) Name() string {
+	return "sht4x"
+}
+
+func (sht4x *SHT4X) Family(name string) bool {
+	return len(name) == 5 && strings.HasPrefix(strings.ToLower(name), "sht4")
+}
+
+func (sht4x *SHT4X) Collect() []sensors.MeasurementRecording {
+	command := SHT4X_MEASURE_HIGH
+	switch sht4x.config.Precision {
+	case SHT_PRECISION_MEDIUM:
+		command = SHT4X_MEASURE_MEDIUM
+	case SHT_PRECISION_LOW:
+		command = SHT4X_MEASURE_LOW
+	}
+	if err := sht4x.readData(command); err != nil {
+		log.ErrorLog.Printf("Failed to measure: %q", err)
+		return nil
+	}
+
+	measurements := make([]sensors.MeasurementRecording, 0)
+	measurements = append(measurements, sensors.MeasurementRecording{
+		Measure: &sensors.Temperature,
+		Value:   sht4x.data.Temperature,
+		Sensor:  sht4x.Name(),
+	})
+	measurements = append(measurements, sensors.MeasurementRecording{
+		Measure: &sensors.Humidity,
+		Value:   sht4x.data.Humidity,
+		Sensor:  sht4x.Name(),
+	})
+	return measurements
+}
+
+func (sht4x *SHT4X) Operations() map[string]sensors.Operation {
+	return map[string]sensors.Operation{
+		"info": {
+			Description: "Serial number and settings",
+			Run: func(args map[string]string) (string, error) {
+				return fmt.Sprintf("SerialNumber: %s\nPrecision: %s", sht4x.serialNumber, sht4x.config.Precision), nil
+			},
+		},
+		"heater": {
+			Description: "Run the heater at 200, 110 or 20 mW for 1s or 100ms, e.g. to evaporate condensation. " +
+				"Keep it below a 10% duty cycle",
+			Args: []string{"power", "duration"},
+			Run: func(args map[string]string) (string, error) {
+				command, ok := sht4xHeaters[args["power"]][args["duration"]]
+				if !ok {
+					return "", fmt.Errorf("unsupported heater setting %smW for %s, available: %s", args["power"], args["duration"], heaterSettings())
+				}
+				if err := sht4x.readData(command); err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("Heated, temperature at the end: %.2fC", sht4x.data.Temperature), nil
+			},
+		},
+	}
+}
+
+func heaterSettings() string {
+	settings := make([]string, 0, len(sht4xHeaters)*2)
+	for power, durations := range sht4xHeaters {
+		for duration := range durations {
+			settings = append(settings, power+"mW "+duration)
+		}
+	}
+	sort.Strings(settings)
+	return strings.Join(settings, ", ")
+}
+
+// readData runs a measurement or heater command and stores the measurement it returns.
+func (sht4x *SHT4X) readData(command Command) error {
+	response, err := command.Read(sht4x.device, &sht4x.mu)
+	if err != nil {
+		return err
+	}
+
+	sht4x.data.Temperature = shtTemperature(binary.BigEndian.Uint16(response[0:2]))
+	// The conversion can leave the 0-100% range, the datasheet recommends cropping it.
+	humidity := -6 + 125*float64(binary.BigEndian.Uint16(response[2:4]))/65535
+	sht4x.data.Humidity = math.Min(math.Max(humidity, 0), 100)
+	return nil
+}
+
+func (sht4x *SHT4X) SerialNumber() error {
+	response, err := SHT4X_SERIALNUMBER.Read(sht4x.device, &sht4x.mu)
+	if err != nil {
+		return err
+	}
+
+	sht4x.serialNumber = fmt.Sprintf("%X", response)
+	return nil
+}
@@ -1,9 +1,13 @@
 package sensors
 
 import (
+	"fmt"
+	"reflect"
+	"strings"
 	"sync"
 	"sync/atomic"
 
+	"azuremyst.org/go-home-sensors/serial"
 	"periph.io/x/conn/v3/i2c"
 )
 
@@ -14,29 +18,78 @@ type Sensor interface {
 	Collect() []MeasurementRecording
 }
 
+// SerialSensor is implemented by sensors connected to a serial port instead of the I²C bus. They
+// get the port configured in [sensors.<name>.serial] and Initialize is not called. The sensor
+// owns the port and closes it in Close.
+type SerialSensor interface {
+	InitializeSerial(port *serial.Port)
+}
+
+// Configurable is implemented by sensors with options beyond the register, decode fills the given
+// struct from the config section of the sensor. Configure is called before Initialize.
+type Configurable interface {
+	Configure(decode func(v interface{}) error) error
+}
+
+// Observer is implemented by sensors that use the readings of other sensors, e.g. for compensation.
+// Observe is called with all the readings of each collection cycle.
+type Observer interface {
+	Observe(recordings []MeasurementRecording)
+}
+
+// Operation is a maintenance action run on demand, e.g. a forced recalibration.
+type Operation struct {
+	Description string
+	Args        []string // Names of the arguments Run expects
+	Run         func(args map[string]string) (string, error)
+}
+
+// Maintainable is implemented by sensors offering maintenance operations, keyed by name.
+type Maintainable interface {
+	Operations() map[string]Operation
+}
+
 // Sensors is the list of supported sensors.
 var (
 	sensorsMu     sync.Mutex
 	atomicSensors atomic.Value
 )
 
+// RegisterSensor adds a sensor to the supported ones. It must be a pointer to the zero value of
+// the sensor type, Sniff creates the instances of the config sections from its type.
 func RegisterSensor(sensor Sensor) {
+	if reflect.TypeOf(sensor).Kind() != reflect.Ptr {
+		panic(fmt.Sprintf("sensors: RegisterSensor of %T, sensors must be registered as pointers", sensor))
+	}
 	sensorsMu.Lock()
 	sensors, _ := atomicSensors.Load().([]Sensor)
 	atomicSensors.Store(append(sensors, sensor))
 	sensorsMu.Unlock()
 }
 
-func Sniff(family string) *Sensor {
+// Sniff returns a new instance of the sensor supporting the family of the config section, so every
+// section configures and initializes its own device. Sensors are registered as pointers to their
+// zero value.
+func Sniff(section string) *Sensor {
+	family := SectionFamily(section)
 	sensors, _ := atomicSensors.Load().([]Sensor)
 	for _, s := range sensors {
 		if s.Family(family) {
-			return &s
+			instance := reflect.New(reflect.TypeOf(s).Elem()).Interface().(Sensor)
+			return &instance
 		}
 	}
 	return nil
 }
 
+// SectionFamily returns the sensor family of a config section. Sections named <family>_<suffix>,
+// e.g. [sensors.sen5x_office], configure further devices of the family and report their readings
+// with the section name as sensor.
+func SectionFamily(section string) string {
+	family, _, _ := strings.Cut(section, "_")
+	return family
+}
+
 func Supported() []string {
 	sensors, _ := atomicSensors.Load().([]Sensor)
 	supportedList := make([]string, len(sensors))
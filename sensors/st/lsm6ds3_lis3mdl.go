@@ -1,3 +1,541 @@
 package st
 
-//WIP
\ No newline at end of file
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"azuremyst.org/go-home-sensors/log"
+	"azuremyst.org/go-home-sensors/sensors"
+	"azuremyst.org/go-home-sensors/sensors/internal/registers"
+	"periph.io/x/conn/v3/i2c"
+)
+
+// LSM6DS3 accelerometer and gyroscope, as found with the LIS3MDL magnetometer on 9-DoF boards.
+// The two chips have their own address, each is configured in its own section.
+//
+// A single reading per collection cycle misses vibrations and movements between the cycles, so the
+// LSM6DS3 stores its samples in its FIFO at the output data rate. The FIFO is drained in the
+// background and the samples of each collection interval are aggregated per axis.
+
+const (
+	LSM6DS3_CHIP_ID    = uint8(0x69) // Also the LSM6DS33
+	LSM6DS3TR_CHIP_ID  = uint8(0x6A) // LSM6DS3TR-C
+	LSM6DS3_REG_WHOAMI = uint8(0x0F)
+	LSM6DS3_REG_CTRL1  = uint8(0x10) // Accelerometer ODR and full scale
+	LSM6DS3_REG_CTRL2  = uint8(0x11) // Gyroscope ODR and full scale
+	LSM6DS3_REG_CTRL3  = uint8(0x12)
+
+	LSM6DS3_REG_FIFO_CTRL3  = uint8(0x08) // Gyroscope and accelerometer decimation
+	LSM6DS3_REG_FIFO_CTRL5  = uint8(0x0A) // FIFO ODR and mode
+	LSM6DS3_REG_FIFO_STATUS = uint8(0x3A) // Unread words, overrun and position in the pattern
+	LSM6DS3_LEN_FIFO_STATUS = 4
+	LSM6DS3_REG_FIFO_OUT    = uint8(0x3E) // 16 bits little endian, the address rolls back while reading
+
+	LSM6DS3_CTRL3_BDU        = uint8(0x40) // Block data update, no mixed samples
+	LSM6DS3_CTRL3_IF_INC     = uint8(0x04) // Register address auto increment
+	LSM6DS3_CTRL3_SW_RST     = uint8(0x01)
+	LSM6DS3_ODR_POS          = uint8(4)
+	LSM6DS3_FIFO_DEC_G_POS   = uint8(3)
+	LSM6DS3_FIFO_ODR_POS     = uint8(3)
+	LSM6DS3_FIFO_CONTINUOUS  = uint8(0x06)
+	LSM6DS3_FIFO_OVERRUN     = uint8(0x40)
+	LSM6DS3_FIFO_DIFF_MSK    = uint8(0x0F)
+	LSM6DS3_FIFO_PATTERN_MSK = uint8(0x03)
+	LSM6DS3_FIFO_WORDS       = 2048 // Of the LSM6DS3TR-C, the LSM6DS3 holds 4096
+	LSM6DS3_FIFO_READ_WORDS  = 120  // Per transfer
+	LSM6DS3_DEFAULT_ODR      = 104
+	LSM6DS3_DEFAULT_ACCEL    = 2
+	LSM6DS3_DEFAULT_GYRO     = 245
+)
+
+const (
+	LIS3MDL_CHIP_ID    = uint8(0x3D)
+	LIS3MDL_REG_WHOAMI = uint8(0x0F)
+	LIS3MDL_REG_CTRL1  = uint8(0x20) // Temperature, X/Y performance mode and ODR
+	LIS3MDL_REG_CTRL2  = uint8(0x21) // Full scale
+	LIS3MDL_REG_CTRL3  = uint8(0x22) // Operating mode
+	LIS3MDL_REG_CTRL4  = uint8(0x23) // Z performance mode
+	LIS3MDL_REG_CTRL5  = uint8(0x24)
+	LIS3MDL_REG_OUT    = uint8(0x28) // X, Y, Z, 16 bits little endian each
+	LIS3MDL_LEN_OUT    = 6
+	LIS3MDL_AUTO_INC   = uint8(0x80) // Set on the register address to read several registers
+
+	LIS3MDL_ULTRA_HIGH_XY        = uint8(0x60)
+	LIS3MDL_ULTRA_HIGH_Z         = uint8(0x0C)
+	LIS3MDL_ODR_POS              = uint8(2)
+	LIS3MDL_SOFT_RST             = uint8(0x04)
+	LIS3MDL_CONTINUOUS           = uint8(0x00)
+	LIS3MDL_POWER_DOWN           = uint8(0x03)
+	LIS3MDL_CTRL5_BDU            = uint8(0x40)
+	LIS3MDL_DEFAULT_ODR          = 10
+	LIS3MDL_DEFAULT_RANGE        = 4
+	LIS3MDL_MICROTESLA_PER_GAUSS = 100
+)
+
+const standardGravity = 9.80665 // m/s² per g
+
+var axes = []string{"x", "y", "z"}
+
+// Output data rates in Hz, indexed by register value. 0 powers the LSM6DS3 down.
+var (
+	lsm6ds3ODRs = []float64{0, 12.5, 26, 52, 104, 208, 416, 833, 1660}
+	lis3mdlODRs = []float64{0.625, 1.25, 2.5, 5, 10, 20, 40, 80}
+)
+
+// FIFO decimation register values by decimation factor.
+var lsm6ds3Decimations = map[int]uint8{1: 1, 2: 2, 3: 3, 4: 4, 8: 5, 16: 6, 32: 7}
+
+// fullScale is a measurement range with its bits in the control register and its resolution.
+type fullScale struct {
+	bits        uint8
+	sensitivity float64 // Unit of the measurement per LSB
+}
+
+var (
+	// ±g, sensitivity in mg/LSB
+	lsm6ds3AccelScales = map[uint16]fullScale{2: {0x00, 0.061}, 4: {0x08, 0.122}, 8: {0x0C, 0.244}, 16: {0x04, 0.488}}
+	// ±dps, sensitivity in mdps/LSB. 125dps has its own bit.
+	lsm6ds3GyroScales = map[uint16]fullScale{125: {0x02, 4.375}, 245: {0x00, 8.75}, 500: {0x04, 17.5},
+		1000: {0x08, 35}, 2000: {0x0C, 70}}
+	// ±gauss, sensitivity in LSB/gauss
+	lis3mdlScales = map[uint16]fullScale{4: {0x00, 6842}, 8: {0x20, 3421}, 12: {0x40, 2281}, 16: {0x60, 1711}}
+)
+
+// LSM6DS3Config holds the options of the [sensors.lsm6ds3] section.
+type LSM6DS3Config struct {
+	// Output data rates in Hz: 12.5, 26, 52, 104 (default), 208, 416, 833 or 1660. Vibrations up
+	// to about half the rate are seen in the aggregates. The rates differ by a factor 32 at most,
+	// rates above 416Hz on both sensors need a 400kHz bus to drain the FIFO in time.
+	AccelerometerODR float64 `toml:"accelerometer_odr"`
+	GyroscopeODR     float64 `toml:"gyroscope_odr"`
+	// Full scale of the accelerometer in g: 2 (default), 4, 8 or 16.
+	AccelerometerRange uint16 `toml:"accelerometer_range"`
+	// Full scale of the gyroscope in dps: 125, 245 (default), 500, 1000 or 2000.
+	GyroscopeRange uint16 `toml:"gyroscope_range"`
+}
+
+// LIS3MDLConfig holds the options of the [sensors.lis3mdl] section.
+type LIS3MDLConfig struct {
+	// Output data rate in Hz: 0.625, 1.25, 2.5, 5, 10 (default), 20, 40 or 80.
+	ODR float64 `toml:"odr"`
+	// Full scale in gauss: 4 (default), 8, 12 or 16. 1 gauss is 100µT.
+	Range uint16 `toml:"range"`
+}
+
+// decodeAxes converts the raw X, Y, Z values, 16 bits little endian each.
+func decodeAxes(data []byte, scale float64) [3]float64 {
+	var values [3]float64
+	for i := range values {
+		values[i] = float64(int16(binary.LittleEndian.Uint16(data[2*i:2*i+2]))) * scale
+	}
+	return values
+}
+
+func axisRecordings(measure *sensors.Measurement, sensor string, values [3]float64) []sensors.MeasurementRecording {
+	measurements := make([]sensors.MeasurementRecording, 0, len(axes))
+	for i, axis := range axes {
+		measurements = append(measurements, sensors.MeasurementRecording{
+			Measure:  measure,
+			Value:    values[i],
+			Sensor:   sensor,
+			Metadata: map[sensors.Metadata]string{sensors.Axis: axis},
+		})
+	}
+	return measurements
+}
+
+// axisStats aggregates the samples of an axis over a collection interval.
+type axisStats struct {
+	samples         int
+	sum, sumSquares float64
+	min, max, last  float64
+}
+
+func (stats *axisStats) add(value float64) {
+	if stats.samples == 0 || value < stats.min {
+		stats.min = value
+	}
+	if stats.samples == 0 || value > stats.max {
+		stats.max = value
+	}
+	stats.samples++
+	stats.sum += value
+	stats.sumSquares += value * value
+	stats.last = value
+}
+
+// rms is the root mean square of the deviations from the mean, the gravity or any offset removed.
+func (stats *axisStats) rms() float64 {
+	mean := stats.sum / float64(stats.samples)
+	return math.Sqrt(math.Max(stats.sumSquares/float64(stats.samples)-mean*mean, 0))
+}
+
+func (stats *axisStats) peak() float64 {
+	return math.Max(math.Abs(stats.min), math.Abs(stats.max))
+}
+
+// fifoPattern lists the data sets of the repeating FIFO pattern in order, true for the gyroscope.
+// At every tick of the FIFO rate the gyroscope set comes before the accelerometer one, a decimated
+// sensor only stores a set every decimation ticks.
+func fifoPattern(gyroDecimation int, accelDecimation int) []bool {
+	ticks := gyroDecimation
+	if accelDecimation > ticks {
+		ticks = accelDecimation
+	}
+	pattern := make([]bool, 0, 2*ticks)
+	for tick := 0; tick < ticks; tick++ {
+		if tick%gyroDecimation == 0 {
+			pattern = append(pattern, true)
+		}
+		if tick%accelDecimation == 0 {
+			pattern = append(pattern, false)
+		}
+	}
+	return pattern
+}
+
+func encodeODR(odr float64, defaultODR float64, supported []float64) (uint8, error) {
+	if odr == 0 {
+		odr = defaultODR
+	}
+	rates := make([]string, 0, len(supported))
+	for code, v := range supported {
+		if v == 0 {
+			continue
+		}
+		if v == odr {
+			return uint8(code), nil
+		}
+		rates = append(rates, fmt.Sprint(v))
+	}
+	return 0, fmt.Errorf("unsupported output data rate %vHz, supported: %s", odr, strings.Join(rates, ", "))
+}
+
+// lookupScale returns the full scale of the range, setting the default one when unset.
+func lookupScale(name string, value *uint16, defaultValue uint16, scales map[uint16]fullScale) (fullScale, error) {
+	if *value == 0 {
+		*value = defaultValue
+	}
+	scale, ok := scales[*value]
+	if !ok {
+		return fullScale{}, fmt.Errorf("unsupported %s range %d", name, *value)
+	}
+	return scale, nil
+}
+
+type LSM6DS3 struct {
+	registers.Registers
+
+	config          LSM6DS3Config
+	accelODR        uint8
+	gyroODR         uint8
+	fifoODR         uint8
+	accelDecimation int
+	gyroDecimation  int
+	accelScale      fullScale
+	gyroScale       fullScale
+	pattern         []bool
+	ready           bool
+
+	mu      sync.Mutex // Guards the fields below, shared with the draining goroutine
+	accel   [3]axisStats
+	gyro    [3]axisStats
+	failing bool
+	overrun bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type LIS3MDL struct {
+	registers.Registers
+
+	config LIS3MDLConfig
+	odr    uint8
+	scale  fullScale
+	ready  bool
+}
+
+func init() {
+	sensors.RegisterSensor(&LSM6DS3{})
+	sensors.RegisterSensor(&LIS3MDL{})
+}
+
+func (lsm *LSM6DS3) Configure(decode func(v interface{}) error) error {
+	if err := decode(&lsm.config); err != nil {
+		return err
+	}
+
+	var err error
+	if lsm.accelODR, err = encodeODR(lsm.config.AccelerometerODR, LSM6DS3_DEFAULT_ODR, lsm6ds3ODRs); err != nil {
+		return err
+	}
+	if lsm.gyroODR, err = encodeODR(lsm.config.GyroscopeODR, LSM6DS3_DEFAULT_ODR, lsm6ds3ODRs); err != nil {
+		return err
+	}
+	// The FIFO runs at the faster rate, every rate is twice the previous one.
+	lsm.fifoODR = lsm.accelODR
+	if lsm.gyroODR > lsm.fifoODR {
+		lsm.fifoODR = lsm.gyroODR
+	}
+	lsm.accelDecimation, lsm.gyroDecimation = 1<<(lsm.fifoODR-lsm.accelODR), 1<<(lsm.fifoODR-lsm.gyroODR)
+	_, accelOK := lsm6ds3Decimations[lsm.accelDecimation]
+	if _, gyroOK := lsm6ds3Decimations[lsm.gyroDecimation]; !accelOK || !gyroOK {
+		return fmt.Errorf("the accelerometer and gyroscope rates differ by more than a factor 32")
+	}
+	lsm.pattern = fifoPattern(lsm.gyroDecimation, lsm.accelDecimation)
+
+	if lsm.accelScale, err = lookupScale("accelerometer", &lsm.config.AccelerometerRange, LSM6DS3_DEFAULT_ACCEL, lsm6ds3AccelScales); err != nil {
+		return err
+	}
+	lsm.gyroScale, err = lookupScale("gyroscope", &lsm.config.GyroscopeRange, LSM6DS3_DEFAULT_GYRO, lsm6ds3GyroScales)
+	return err
+}
+
+func (lsm *LSM6DS3) Initialize(bus i2c.Bus, addr uint16) {
+	lsm.Device = &i2c.Dev{Addr: addr, Bus: bus}
+
+	chipID, err := lsm.ChipID(LSM6DS3_REG_WHOAMI, LSM6DS3_CHIP_ID, LSM6DS3TR_CHIP_ID)
+	if err != nil {
+		log.ErrorLog.Printf("Failed to find LSM6DS3: %q", err)
+		return
+	}
+	if err := lsm.SetRegs([]byte{LSM6DS3_REG_CTRL3, LSM6DS3_CTRL3_SW_RST}); err != nil {
+		log.ErrorLog.Printf("Failed to reset device: %q", err)
+		return
+	}
+	time.Sleep(10 * time.Millisecond)
+	settings := [][]byte{
+		{LSM6DS3_REG_CTRL3, LSM6DS3_CTRL3_BDU | LSM6DS3_CTRL3_IF_INC},
+		{LSM6DS3_REG_CTRL1, lsm.accelODR<<LSM6DS3_ODR_POS | lsm.accelScale.bits},
+		{LSM6DS3_REG_CTRL2, lsm.gyroODR<<LSM6DS3_ODR_POS | lsm.gyroScale.bits},
+		{LSM6DS3_REG_FIFO_CTRL3, lsm6ds3Decimations[lsm.gyroDecimation]<<LSM6DS3_FIFO_DEC_G_POS | lsm6ds3Decimations[lsm.accelDecimation]},
+		{LSM6DS3_REG_FIFO_CTRL5, lsm.fifoODR<<LSM6DS3_FIFO_ODR_POS | LSM6DS3_FIFO_CONTINUOUS},
+	}
+	for _, setting := range settings {
+		if err := lsm.SetRegs(setting); err != nil {
+			log.ErrorLog.Printf("Failed to configure device: %q", err)
+			return
+		}
+	}
+	lsm.ready = true
+
+	log.InfoLog.Printf("ST LSM6DS3\n\tChipID: 0x%02x\n\tAccelerometer: %vHz ±%dg\n\tGyroscope: %vHz ±%ddps", chipID,
+		lsm6ds3ODRs[lsm.accelODR], lsm.config.AccelerometerRange, lsm6ds3ODRs[lsm.gyroODR], lsm.config.GyroscopeRange)
+
+	lsm.stop = make(chan struct{})
+	lsm.done = make(chan struct{})
+	go lsm.sample()
+}
+
+// sample drains the FIFO until Close, often enough for it to never be more than half full.
+func (lsm *LSM6DS3) sample() {
+	defer close(lsm.done)
+	wordsPerSecond := 3 * (lsm6ds3ODRs[lsm.accelODR] + lsm6ds3ODRs[lsm.gyroODR])
+	interval := time.Duration(LSM6DS3_FIFO_WORDS / 2 / wordsPerSecond * float64(time.Second))
+	if interval > time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-lsm.stop:
+			return
+		case <-ticker.C:
+		}
+
+		err := lsm.drain()
+		lsm.mu.Lock()
+		if err != nil && !lsm.failing {
+			log.ErrorLog.Printf("Failed to read FIFO: %q", err)
+		} else if err == nil && lsm.failing {
+			log.InfoLog.Printf("FIFO read again")
+		}
+		lsm.failing = err != nil
+		lsm.mu.Unlock()
+	}
+}
+
+// drain reads the whole data sets in the FIFO into the aggregates.
+func (lsm *LSM6DS3) drain() error {
+	status, err := lsm.ReadRegs(LSM6DS3_REG_FIFO_STATUS, LSM6DS3_LEN_FIFO_STATUS)
+	if err != nil {
+		return err
+	}
+	unread := int(status[1]&LSM6DS3_FIFO_DIFF_MSK)<<8 | int(status[0])
+	// Word of the pattern read next, a data set being 3 words.
+	position := int(status[3]&LSM6DS3_FIFO_PATTERN_MSK)<<8 | int(status[2])
+	if status[1]&LSM6DS3_FIFO_OVERRUN != 0 && !lsm.overrun {
+		log.ErrorLog.Printf("FIFO overrun, samples were lost. Is the bus too slow for the output data rates?")
+		lsm.overrun = true
+	}
+	// The last data set may still be incomplete, it is read with the next ones.
+	unread -= (position + unread) % 3
+	if unread < 3 {
+		return nil
+	}
+
+	words := make([]byte, 0, 2*unread)
+	for len(words) < cap(words) {
+		length := cap(words) - len(words)
+		if length > 2*LSM6DS3_FIFO_READ_WORDS {
+			length = 2 * LSM6DS3_FIFO_READ_WORDS
+		}
+		data, err := lsm.ReadRegs(LSM6DS3_REG_FIFO_OUT, uint8(length))
+		if err != nil {
+			return err
+		}
+		words = append(words, data...)
+	}
+
+	lsm.mu.Lock()
+	defer lsm.mu.Unlock()
+	// A data set started before the position, e.g. after an overrun, is skipped.
+	for start := (3 - position%3) % 3; start+3 <= unread; start += 3 {
+		set := (position + start) / 3 % len(lsm.pattern)
+		if lsm.pattern[set] {
+			addSet(&lsm.gyro, decodeAxes(words[2*start:2*start+6], lsm.gyroScale.sensitivity/1000))
+		} else {
+			addSet(&lsm.accel, decodeAxes(words[2*start:2*start+6], lsm.accelScale.sensitivity/1000*standardGravity))
+		}
+	}
+	return nil
+}
+
+func addSet(stats *[3]axisStats, values [3]float64) {
+	for i, value := range values {
+		stats[i].add(value)
+	}
+}
+
+func (lsm *LSM6DS3) Name() string {
+	return "lsm6ds3"
+}
+
+func (lsm *LSM6DS3) Family(name string) bool {
+	return strings.HasPrefix(strings.ToLower(name), lsm.Name())
+}
+
+// Collect reports the last sample and the aggregates of the samples since the previous collection.
+func (lsm *LSM6DS3) Collect() []sensors.MeasurementRecording {
+	lsm.mu.Lock()
+	accel, gyro, failing := lsm.accel, lsm.gyro, lsm.failing
+	lsm.accel, lsm.gyro = [3]axisStats{}, [3]axisStats{}
+	lsm.mu.Unlock()
+	if !lsm.ready || failing {
+		return nil
+	}
+
+	measurements := make([]sensors.MeasurementRecording, 0)
+	if gyro[0].samples > 0 {
+		var last, peak [3]float64
+		for i := range gyro {
+			last[i], peak[i] = gyro[i].last, gyro[i].peak()
+		}
+		measurements = append(measurements, axisRecordings(&sensors.AngularRate, lsm.Name(), last)...)
+		measurements = append(measurements, axisRecordings(&sensors.AngularRatePeak, lsm.Name(), peak)...)
+	}
+	if accel[0].samples > 0 {
+		var last, rms, peakToPeak [3]float64
+		for i := range accel {
+			last[i], rms[i], peakToPeak[i] = accel[i].last, accel[i].rms(), accel[i].max-accel[i].min
+		}
+		measurements = append(measurements, axisRecordings(&sensors.Acceleration, lsm.Name(), last)...)
+		measurements = append(measurements, axisRecordings(&sensors.AccelerationRMS, lsm.Name(), rms)...)
+		measurements = append(measurements, axisRecordings(&sensors.AccelerationPeakToPeak, lsm.Name(), peakToPeak)...)
+	}
+	return measurements
+}
+
+// Close stops draining the FIFO and powers the accelerometer and gyroscope down.
+func (lsm *LSM6DS3) Close() error {
+	if !lsm.ready {
+		return nil
+	}
+	if lsm.stop != nil {
+		close(lsm.stop)
+		<-lsm.done
+		lsm.stop = nil
+	}
+	if err := lsm.SetRegs([]byte{LSM6DS3_REG_CTRL1, 0}); err != nil {
+		return err
+	}
+	return lsm.SetRegs([]byte{LSM6DS3_REG_CTRL2, 0})
+}
+
+func (lis *LIS3MDL) Configure(decode func(v interface{}) error) error {
+	if err := decode(&lis.config); err != nil {
+		return err
+	}
+
+	var err error
+	if lis.odr, err = encodeODR(lis.config.ODR, LIS3MDL_DEFAULT_ODR, lis3mdlODRs); err != nil {
+		return err
+	}
+	lis.scale, err = lookupScale("magnetometer", &lis.config.Range, LIS3MDL_DEFAULT_RANGE, lis3mdlScales)
+	return err
+}
+
+func (lis *LIS3MDL) Initialize(bus i2c.Bus, addr uint16) {
+	lis.Device = &i2c.Dev{Addr: addr, Bus: bus}
+
+	if _, err := lis.ChipID(LIS3MDL_REG_WHOAMI, LIS3MDL_CHIP_ID); err != nil {
+		log.ErrorLog.Printf("Failed to find LIS3MDL: %q", err)
+		return
+	}
+	if err := lis.SetRegs([]byte{LIS3MDL_REG_CTRL2, LIS3MDL_SOFT_RST}); err != nil {
+		log.ErrorLog.Printf("Failed to reset device: %q", err)
+		return
+	}
+	time.Sleep(10 * time.Millisecond)
+	settings := [][]byte{
+		{LIS3MDL_REG_CTRL1, LIS3MDL_ULTRA_HIGH_XY | lis.odr<<LIS3MDL_ODR_POS},
+		{LIS3MDL_REG_CTRL2, lis.scale.bits},
+		{LIS3MDL_REG_CTRL4, LIS3MDL_ULTRA_HIGH_Z},
+		{LIS3MDL_REG_CTRL5, LIS3MDL_CTRL5_BDU},
+		{LIS3MDL_REG_CTRL3, LIS3MDL_CONTINUOUS},
+	}
+	for _, setting := range settings {
+		if err := lis.SetRegs(setting); err != nil {
+			log.ErrorLog.Printf("Failed to configure device: %q", err)
+			return
+		}
+	}
+	lis.ready = true
+
+	log.InfoLog.Printf("ST LIS3MDL\n\tODR: %vHz\n\tRange: ±%dgauss", lis3mdlODRs[lis.odr], lis.config.Range)
+}
+
+func (lis *LIS3MDL) Name() string {
+	return "lis3mdl"
+}
+
+func (lis *LIS3MDL) Family(name string) bool {
+	return strings.EqualFold(lis.Name(), name)
+}
+
+func (lis *LIS3MDL) Collect() []sensors.MeasurementRecording {
+	if !lis.ready {
+		return nil
+	}
+	data, err := lis.ReadRegs(LIS3MDL_REG_OUT|LIS3MDL_AUTO_INC, LIS3MDL_LEN_OUT)
+	if err != nil {
+		log.ErrorLog.Printf("Failed to read measurements: %q", err)
+		return nil
+	}
+	return axisRecordings(&sensors.MagneticField, lis.Name(), decodeAxes(data, LIS3MDL_MICROTESLA_PER_GAUSS/lis.scale.sensitivity))
+}
+
+// Close powers the magnetometer down.
+func (lis *LIS3MDL) Close() error {
+	if !lis.ready {
+		return nil
+	}
+	return lis.SetRegs([]byte{LIS3MDL_REG_CTRL3, LIS3MDL_POWER_DOWN})
+}
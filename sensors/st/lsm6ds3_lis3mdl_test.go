@@ -0,0 +1,132 @@
+package st
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+
+	"periph.io/x/conn/v3/i2c"
+	"periph.io/x/conn/v3/physic"
+)
+
+// fifoBus answers the FIFO status and serves the words of the FIFO in order.
+type fifoBus struct {
+	status []byte
+	words  []int16
+}
+
+func (bus *fifoBus) Tx(addr uint16, w, r []byte) error {
+	switch w[0] {
+	case LSM6DS3_REG_FIFO_STATUS:
+		copy(r, bus.status)
+	case LSM6DS3_REG_FIFO_OUT:
+		for i := 0; i < len(r); i += 2 {
+			binary.LittleEndian.PutUint16(r[i:], uint16(bus.words[0]))
+			bus.words = bus.words[1:]
+		}
+	}
+	return nil
+}
+
+func (bus *fifoBus) SetSpeed(f physic.Frequency) error { return nil }
+
+func (bus *fifoBus) String() string { return "fifo" }
+
+func TestFIFOPattern(t *testing.T) {
+	tests := []struct {
+		gyroDecimation, accelDecimation int
+		pattern                         []bool
+	}{
+		{1, 1, []bool{true, false}},
+		{2, 1, []bool{true, false, false}},
+		{1, 4, []bool{true, false, true, true, true}},
+	}
+	for _, tt := range tests {
+		if pattern := fifoPattern(tt.gyroDecimation, tt.accelDecimation); !reflect.DeepEqual(pattern, tt.pattern) {
+			t.Errorf("fifoPattern(%d, %d) = %v, want %v", tt.gyroDecimation, tt.accelDecimation, pattern, tt.pattern)
+		}
+	}
+}
+
+func TestLSM6DS3Configure(t *testing.T) {
+	tests := []struct {
+		accelODR, gyroODR float64
+		ok                bool
+	}{
+		{104, 104, true},
+		{12.5, 416, true},
+		{1660, 52, true},
+		{12.5, 833, false},
+	}
+	for _, tt := range tests {
+		lsm := &LSM6DS3{}
+		err := lsm.Configure(func(v interface{}) error {
+			*v.(*LSM6DS3Config) = LSM6DS3Config{AccelerometerODR: tt.accelODR, GyroscopeODR: tt.gyroODR}
+			return nil
+		})
+		if (err == nil) != tt.ok {
+			t.Errorf("Configure(%vHz, %vHz) error = %v, want ok %t", tt.accelODR, tt.gyroODR, err, tt.ok)
+		}
+	}
+}
+
+func TestLSM6DS3Drain(t *testing.T) {
+	lsm := &LSM6DS3{accelScale: lsm6ds3AccelScales[2], gyroScale: lsm6ds3GyroScales[245], pattern: fifoPattern(1, 1)}
+	// Starts at the second word of a gyroscope set and ends within an accelerometer set.
+	words := []int16{
+		0, 0, // End of a gyroscope set
+		0, 0, 16393, // 1g on Z
+		1000, -1000, 0,
+		0, 0, 16393,
+		2000, -3000, 0,
+		0, 0, 24590, // 1.5g on Z
+		0, 0, // Incomplete, left in the FIFO
+	}
+	bus := &fifoBus{status: []byte{byte(len(words)), 0, 1, 0}, words: words}
+	lsm.Device = &i2c.Dev{Bus: bus}
+
+	if err := lsm.drain(); err != nil {
+		t.Fatal(err)
+	}
+	if len(bus.words) != 2 {
+		t.Errorf("%d words left in the FIFO, want the 2 of the incomplete set", len(bus.words))
+	}
+	if lsm.gyro[0].samples != 2 || lsm.accel[2].samples != 3 {
+		t.Fatalf("%d gyroscope and %d accelerometer samples, want 2 and 3", lsm.gyro[0].samples, lsm.accel[2].samples)
+	}
+
+	near := func(got, want float64) bool { return math.Abs(got-want) < 0.01 }
+	if peak := lsm.gyro[1].peak(); !near(peak, 26.25) {
+		t.Errorf("gyroscope Y peak = %v, want 26.25dps", peak)
+	}
+	if last := lsm.accel[2].last; !near(last, 1.5*standardGravity) {
+		t.Errorf("last Z acceleration = %v, want %v", last, 1.5*standardGravity)
+	}
+	if peakToPeak := lsm.accel[2].max - lsm.accel[2].min; !near(peakToPeak, 0.5*standardGravity) {
+		t.Errorf("Z acceleration peak to peak = %v, want %v", peakToPeak, 0.5*standardGravity)
+	}
+	// Samples of 1, 1 and 1.5g deviate by √2/6 g from their mean.
+	if rms := lsm.accel[2].rms(); !near(rms, math.Sqrt2/6*standardGravity) {
+		t.Errorf("Z acceleration RMS = %v, want %v", rms, math.Sqrt2/6*standardGravity)
+	}
+	if rms := lsm.accel[0].rms(); rms != 0 {
+		t.Errorf("X acceleration RMS = %v, want 0", rms)
+	}
+}
+
+// Less than a data set in the FIFO is left there, also when the pattern is within a set.
+func TestLSM6DS3DrainPartialSet(t *testing.T) {
+	for _, unread := range []int{0, 1, 2} {
+		lsm := &LSM6DS3{accelScale: lsm6ds3AccelScales[2], gyroScale: lsm6ds3GyroScales[245], pattern: fifoPattern(1, 1)}
+		bus := &fifoBus{status: []byte{byte(unread), 0, 1, 0}, words: make([]int16, unread)}
+		lsm.Device = &i2c.Dev{Bus: bus}
+
+		if err := lsm.drain(); err != nil {
+			t.Fatal(err)
+		}
+		if len(bus.words) != unread || lsm.gyro[0].samples+lsm.accel[0].samples != 0 {
+			t.Errorf("%d unread words: %d left in the FIFO and %d samples, want all left and none", unread, len(bus.words), lsm.gyro[0].samples+lsm.accel[0].samples)
+		}
+	}
+}
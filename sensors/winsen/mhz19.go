@@ -0,0 +1,206 @@
+package winsen
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"azuremyst.org/go-home-sensors/log"
+	"azuremyst.org/go-home-sensors/sensors"
+	"azuremyst.org/go-home-sensors/serial"
+	"periph.io/x/conn/v3/i2c"
+)
+
+// Commands of the 9 byte protocol, see the MH-Z19B/C datasheet.
+const (
+	MHZ19_READ_CO2         = 0x86
+	MHZ19_ZERO_CALIBRATION = 0x87 // Sets the current concentration as 400ppm
+	MHZ19_SPAN_CALIBRATION = 0x88
+	MHZ19_SET_ABC          = 0x79
+	MHZ19_GET_ABC          = 0x7D
+	MHZ19_SET_RANGE        = 0x99
+)
+
+const mhz19FrameSize = 9
+
+// MHZ19Config holds the options of the [sensors.mhz19] section, the port is configured in its
+// serial section. Unset options keep the value stored on the device.
+type MHZ19Config struct {
+	// Automatic baseline correction, assumes the sensor sees fresh air at least once a day.
+	ABCEnabled *bool `toml:"abc_enabled"`
+	// Detection range in ppm, usually 2000, 5000 or 10000.
+	Range *uint16
+}
+
+type MHZ19 struct {
+	port *serial.Port
+	mu   sync.Mutex
+
+	config MHZ19Config
+	co2    float64
+}
+
+func init() {
+	sensors.RegisterSensor(&MHZ19{})
+}
+
+func (mhz19 *MHZ19) Name() string {
+	return "mhz19"
+}
+
+func (mhz19 *MHZ19) Family(name string) bool {
+	return strings.EqualFold(name, mhz19.Name()) || strings.EqualFold(name, "mhz19b") || strings.EqualFold(name, "mhz19c")
+}
+
+func (mhz19 *MHZ19) Configure(decode func(v interface{}) error) error {
+	return decode(&mhz19.config)
+}
+
+// Initialize is not used, the sensor is connected to a serial port.
+func (mhz19 *MHZ19) Initialize(bus i2c.Bus, addr uint16) {
+}
+
+func (mhz19 *MHZ19) InitializeSerial(port *serial.Port) {
+	mhz19.port = port
+
+	if mhz19.config.Range != nil {
+		rangePPM := *mhz19.config.Range
+		if _, err := mhz19.command(MHZ19_SET_RANGE, 0, 0, 0, byte(rangePPM>>8), byte(rangePPM)); err != nil {
+			log.ErrorLog.Printf("Failed to set range: %q", err)
+		}
+	}
+	if mhz19.config.ABCEnabled != nil {
+		if err := mhz19.SetABC(*mhz19.config.ABCEnabled); err != nil {
+			log.ErrorLog.Printf("Failed to set ABC: %q", err)
+		}
+	}
+
+	abc := "unknown"
+	if enabled, err := mhz19.IsABCEnabled(); err == nil {
+		abc = strconv.FormatBool(enabled)
+	}
+	log.InfoLog.Printf("Winsen MH-Z19\n\tPort: %s\n\tABC: %s", port, abc)
+}
+
+func (mhz19 *MHZ19) Collect() []sensors.MeasurementRecording {
+	if mhz19.port == nil {
+		return nil
+	}
+	response, err := mhz19.command(MHZ19_READ_CO2)
+	if err != nil {
+		log.ErrorLog.Printf("Failed to read CO2: %q", err)
+		return nil
+	}
+	mhz19.co2 = float64(uint16(response[2])<<8 | uint16(response[3]))
+
+	return []sensors.MeasurementRecording{{
+		Measure: &sensors.CarbonDioxide,
+		Value:   mhz19.co2,
+		Sensor:  mhz19.Name(),
+	}}
+}
+
+func (mhz19 *MHZ19) Operations() map[string]sensors.Operation {
+	return map[string]sensors.Operation{
+		"zero": {
+			Description: "Zero point calibration to 400ppm, the sensor has to be in fresh air for 20 minutes first",
+			Run: func(args map[string]string) (string, error) {
+				_, err := mhz19.command(MHZ19_ZERO_CALIBRATION)
+				return "Calibrated to 400ppm", err
+			},
+		},
+		"abc": {
+			Description: "Enable or disable the automatic baseline correction",
+			Args:        []string{"enabled"},
+			Run: func(args map[string]string) (string, error) {
+				enable, err := strconv.ParseBool(args["enabled"])
+				if err != nil {
+					return "", fmt.Errorf("invalid enabled %q: %v", args["enabled"], err)
+				}
+				return fmt.Sprintf("ABC: %t", enable), mhz19.SetABC(enable)
+			},
+		},
+	}
+}
+
+func (mhz19 *MHZ19) SetABC(enable bool) error {
+	var value byte
+	if enable {
+		value = 0xA0
+	}
+	_, err := mhz19.command(MHZ19_SET_ABC, value)
+	return err
+}
+
+func (mhz19 *MHZ19) IsABCEnabled() (bool, error) {
+	response, err := mhz19.command(MHZ19_GET_ABC)
+	if err != nil {
+		return false, err
+	}
+	return response[7] == 1, nil
+}
+
+func (mhz19 *MHZ19) Close() error {
+	if mhz19.port == nil {
+		return nil
+	}
+	return mhz19.port.Close()
+}
+
+// command sends the command with its data bytes and returns the response of the read commands,
+// the write commands are not answered.
+func (mhz19 *MHZ19) command(command byte, data ...byte) ([]byte, error) {
+	mhz19.mu.Lock()
+	defer mhz19.mu.Unlock()
+
+	request := mhz19Request(command, data...)
+	if err := mhz19.port.Flush(); err != nil {
+		return nil, err
+	}
+	if _, err := mhz19.port.Write(request); err != nil {
+		return nil, fmt.Errorf("error while sending command 0x%02x: %v", command, err)
+	}
+	if command != MHZ19_READ_CO2 && command != MHZ19_GET_ABC {
+		// Give the sensor time to store the setting before the next command.
+		time.Sleep(100 * time.Millisecond)
+		return nil, nil
+	}
+
+	response := make([]byte, mhz19FrameSize)
+	if err := mhz19.port.ReadFull(response); err != nil {
+		return nil, fmt.Errorf("error while reading response to 0x%02x: %v", command, err)
+	}
+	return response, checkResponse(command, response)
+}
+
+func mhz19Request(command byte, data ...byte) []byte {
+	request := make([]byte, mhz19FrameSize)
+	request[0] = 0xFF
+	request[1] = 0x01
+	request[2] = command
+	copy(request[3:8], data)
+	request[8] = checksum(request)
+	return request
+}
+
+// checkResponse validates the checksum of the response and that it answers the command.
+func checkResponse(command byte, response []byte) error {
+	if response[0] != 0xFF || response[1] != command {
+		return fmt.Errorf("unexpected response % x to 0x%02x", response, command)
+	}
+	if checksum(response) != response[8] {
+		return fmt.Errorf("invalid checksum in response % x", response)
+	}
+	return nil
+}
+
+// checksum is the two's complement of the sum of bytes 1 to 7.
+func checksum(frame []byte) byte {
+	var sum byte
+	for _, b := range frame[1:8] {
+		sum += b
+	}
+	return 0xFF - sum + 1
+}
@@ -0,0 +1,66 @@
+package winsen
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("invalid test frame %q: %v", s, err)
+	}
+	return data
+}
+
+// Commands of the MH-Z19B datasheet.
+func TestRequest(t *testing.T) {
+	tests := []struct {
+		command byte
+		data    []byte
+		want    string
+	}{
+		{MHZ19_READ_CO2, nil, "ff0186000000000079"},
+		{MHZ19_ZERO_CALIBRATION, nil, "ff0187000000000078"},
+		{MHZ19_SET_ABC, []byte{0xA0}, "ff0179a000000000e6"},
+	}
+	for _, tt := range tests {
+		if request := hex.EncodeToString(mhz19Request(tt.command, tt.data...)); request != tt.want {
+			t.Errorf("request 0x%02x % x = %s, want %s", tt.command, tt.data, request, tt.want)
+		}
+	}
+}
+
+func TestCheckResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  byte
+		response string
+		err      string
+	}{
+		// Datasheet example, 608 ppm.
+		{"read co2", MHZ19_READ_CO2, "ff86026047000000d1", ""},
+		{"bad checksum", MHZ19_READ_CO2, "ff86026047000000d2", "invalid checksum"},
+		{"other command", MHZ19_GET_ABC, "ff86026047000000d1", "unexpected response"},
+		{"bad start", MHZ19_READ_CO2, "fe86026047000000d1", "unexpected response"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := mustDecodeHex(t, tt.response)
+			err := checkResponse(tt.command, response)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("checkResponse(% x) = %v", response, err)
+				}
+				if co2 := binary.BigEndian.Uint16(response[2:4]); co2 != 608 {
+					t.Errorf("co2 = %d, want 608", co2)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("checkResponse(% x) = %v, want %q", response, err, tt.err)
+			}
+		})
+	}
+}
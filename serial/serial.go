@@ -0,0 +1,118 @@
+package serial
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const defaultTimeout = 3 * time.Second
+
+const (
+	ParityNone = "none"
+	ParityEven = "even"
+	ParityOdd  = "odd"
+)
+
+// Config holds the [sensors.<name>.serial] section, unset options default to 9600 baud 8N1, the
+// framing of nearly every UART sensor.
+type Config struct {
+	Port     string // e.g. /dev/ttyS0 or /dev/ttyUSB0
+	Baud     int
+	DataBits int    `toml:"data_bits"`
+	Parity   string // none, even or odd
+	StopBits int    `toml:"stop_bits"`
+	// Longest wait for data in a single read, defaults to 3 seconds so the sensors sending a frame
+	// every 2.3 seconds, like the Plantower ones in active mode, are not read too early.
+	Timeout time.Duration
+}
+
+func (conf *Config) withDefaults() Config {
+	c := *conf
+	if c.Baud == 0 {
+		c.Baud = 9600
+	}
+	if c.DataBits == 0 {
+		c.DataBits = 8
+	}
+	if c.Parity == "" {
+		c.Parity = ParityNone
+	}
+	if c.StopBits == 0 {
+		c.StopBits = 1
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultTimeout
+	}
+	return c
+}
+
+func (conf *Config) validate() error {
+	if conf.Port == "" {
+		return fmt.Errorf("serial port is required")
+	}
+	if conf.DataBits < 5 || conf.DataBits > 8 {
+		return fmt.Errorf("invalid data bits %d", conf.DataBits)
+	}
+	if conf.StopBits != 1 && conf.StopBits != 2 {
+		return fmt.Errorf("invalid stop bits %d", conf.StopBits)
+	}
+	switch conf.Parity {
+	case ParityNone, ParityEven, ParityOdd:
+	default:
+		return fmt.Errorf("invalid parity %q", conf.Parity)
+	}
+	return nil
+}
+
+// Port is an open serial port in raw mode, locked for this process.
+type Port struct {
+	file    *os.File
+	timeout time.Duration
+}
+
+// Open configures and opens the port, it fails when another process uses the port.
+func Open(conf Config) (*Port, error) {
+	conf = conf.withDefaults()
+	if err := conf.validate(); err != nil {
+		return nil, err
+	}
+	file, err := open(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &Port{file: file, timeout: conf.Timeout}, nil
+}
+
+// Read reads the available bytes, waiting at most the configured timeout for the first one.
+func (p *Port) Read(b []byte) (int, error) {
+	if err := p.file.SetReadDeadline(time.Now().Add(p.timeout)); err != nil {
+		return 0, err
+	}
+	return p.file.Read(b)
+}
+
+// ReadFull fills b, each read waiting at most the configured timeout.
+func (p *Port) ReadFull(b []byte) error {
+	_, err := io.ReadFull(p, b)
+	return err
+}
+
+func (p *Port) Write(b []byte) (int, error) {
+	return p.file.Write(b)
+}
+
+// Flush drops the received bytes nobody read yet, e.g. the stale frames of a sensor sending
+// continuously.
+func (p *Port) Flush() error {
+	return flush(p.file)
+}
+
+func (p *Port) Close() error {
+	return p.file.Close()
+}
+
+func (p *Port) String() string {
+	return p.file.Name()
+}
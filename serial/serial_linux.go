@@ -0,0 +1,99 @@
+//go:build linux
+
+package serial
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+var baudRates = map[int]uint32{
+	1200:   unix.B1200,
+	2400:   unix.B2400,
+	4800:   unix.B4800,
+	9600:   unix.B9600,
+	19200:  unix.B19200,
+	38400:  unix.B38400,
+	57600:  unix.B57600,
+	115200: unix.B115200,
+	230400: unix.B230400,
+}
+
+var dataBits = map[int]uint32{5: unix.CS5, 6: unix.CS6, 7: unix.CS7, 8: unix.CS8}
+
+func open(conf Config) (*os.File, error) {
+	speed, ok := baudRates[conf.Baud]
+	if !ok {
+		return nil, fmt.Errorf("unsupported baud rate %d", conf.Baud)
+	}
+
+	file, err := os.OpenFile(conf.Port, os.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	err = control(file, func(fd int) error {
+		if err := unix.Flock(fd, unix.LOCK_EX|unix.LOCK_NB); err != nil {
+			if errors.Is(err, unix.EWOULDBLOCK) {
+				return fmt.Errorf("serial port %s is in use by another process, is the daemon running?", conf.Port)
+			}
+			return fmt.Errorf("failed to lock %s: %v", conf.Port, err)
+		}
+
+		termios, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+		if err != nil {
+			return fmt.Errorf("%s is not a serial port: %v", conf.Port, err)
+		}
+		termios.Iflag = 0
+		termios.Oflag = 0
+		termios.Lflag = 0
+		termios.Cflag = dataBits[conf.DataBits] | unix.CREAD | unix.CLOCAL | speed
+		switch conf.Parity {
+		case ParityEven:
+			termios.Cflag |= unix.PARENB
+		case ParityOdd:
+			termios.Cflag |= unix.PARENB | unix.PARODD
+		}
+		if conf.StopBits == 2 {
+			termios.Cflag |= unix.CSTOPB
+		}
+		termios.Ispeed = speed
+		termios.Ospeed = speed
+		// Reads block until a byte arrives, the timeouts are handled with read deadlines.
+		termios.Cc[unix.VMIN] = 1
+		termios.Cc[unix.VTIME] = 0
+		if err := unix.IoctlSetTermios(fd, unix.TCSETS, termios); err != nil {
+			return fmt.Errorf("failed to configure %s: %v", conf.Port, err)
+		}
+		return unix.IoctlSetInt(fd, unix.TCFLSH, unix.TCIFLUSH)
+	})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+func flush(file *os.File) error {
+	return control(file, func(fd int) error {
+		return unix.IoctlSetInt(fd, unix.TCFLSH, unix.TCIFLUSH)
+	})
+}
+
+// control runs fn on the file descriptor without File.Fd, which would switch the file to
+// blocking mode and disable the read deadlines.
+func control(file *os.File, fn func(fd int) error) error {
+	raw, err := file.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var fnErr error
+	if err := raw.Control(func(fd uintptr) {
+		fnErr = fn(int(fd))
+	}); err != nil {
+		return err
+	}
+	return fnErr
+}
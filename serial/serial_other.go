@@ -0,0 +1,16 @@
+//go:build !linux
+
+package serial
+
+import (
+	"fmt"
+	"os"
+)
+
+func open(conf Config) (*os.File, error) {
+	return nil, fmt.Errorf("serial ports are only supported on linux")
+}
+
+func flush(file *os.File) error {
+	return nil
+}